@@ -5,13 +5,13 @@ go 1.17
 require (
 	github.com/golang/protobuf v1.5.2
 	golang.org/x/net v0.2.0
+	golang.org/x/sys v0.2.0
 	golang.org/x/text v0.4.0
 	google.golang.org/grpc v1.51.0
 	layeh.com/radius v0.0.0-20210819152912-ad72663a72ab
 )
 
 require (
-	golang.org/x/sys v0.2.0 // indirect
 	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 	google.golang.org/protobuf v1.27.1 // indirect
 )
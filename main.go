@@ -5,12 +5,20 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
 	"goapistress/model"
 	"goapistress/server"
+	"goapistress/server/metrics"
+	"goapistress/server/push"
+	"goapistress/server/report"
+	"goapistress/server/resultlog"
+	"goapistress/server/statistics"
 )
 
 // array 自定义数组参数
@@ -47,6 +55,63 @@ var (
 	taskTimeout       int    = 3600    // task timeout context 控制
 )
 
+// 压测任务参数
+var (
+	duration    time.Duration // 压测时长，与请求数同时设置时先到先结束
+	rate        float64       // 开放模型 固定到达速率(每秒请求数)
+	maxInFlight uint64        // 开放模型 最大并发请求数
+	stages      string        // 分阶段压测
+	warmUp      time.Duration // 预热时长
+	warmUpNum   uint64        // 预热请求数
+	coCorrect   bool          // 协调遗漏修正
+	coInterval  time.Duration // 闭合模型 协调遗漏修正的期望请求间隔
+	pacing      time.Duration // 闭合模型 每个协程两次请求发起的间隔
+	thinkTime   string        // 闭合模型 每个协程两次请求之间的思考时间
+)
+
+// 容量搜索参数
+var (
+	search           = ""                     // 容量搜索 起始负载:每级增加:最大负载
+	searchStep       = 30 * time.Second       // 容量搜索 每级压测时长
+	searchThresholds = "p99<1s,error_rate<1%" // 容量搜索 判定条件
+)
+
+// 统计输出参数
+var (
+	precision   = 3          // 耗时直方图有效数字位数
+	percentiles = "90,95,99" // 输出的耗时百分位
+	timeSeries  = ""         // 每秒统计数据导出文件
+	tui         = false      // 全屏终端面板
+)
+
+// 判定条件参数
+var (
+	thresholdExprs = "" // 压测结束时的判定条件
+	abortExprs     = "" // 提前结束压测的条件
+)
+
+// 压测报告参数
+var (
+	reportJSON  = "" // JSON 格式压测报告文件
+	reportJUnit = "" // JUnit XML 格式压测报告文件
+	reportHTML  = "" // HTML 格式压测报告文件
+)
+
+// 实时指标参数
+var (
+	metricsAddr = "" // Prometheus 指标监听地址
+	statsdAddr  = "" // StatsD 推送地址
+	influxAddr  = "" // InfluxDB 行协议推送地址
+	pushTags    = "" // 推送的标签
+)
+
+// 请求结果日志参数
+var (
+	resultsLog    = ""              // 请求结果日志文件
+	resultsSample = 1.0             // 成功请求的采样比例
+	resultLog     *resultlog.Writer // 请求结果日志
+)
+
 func init() {
 	flag.Uint64Var(&concurrency, "c", concurrency, "并发数")
 	flag.Uint64Var(&reqNumbersPerProd, "n", reqNumbersPerProd, "请求数(单个并发/协程)")
@@ -63,23 +128,182 @@ func init() {
 	flag.BoolVar(&keepalive, "k", keepalive, "是否开启长连接")
 	flag.IntVar(&cpuNumber, "cpuNumber", cpuNumber, "CPU 核数，默认为一核")
 	flag.IntVar(&clientTimeout, "clientTimeout", clientTimeout, "超时时间 单位 秒,默认30")
-	flag.IntVar(&taskTimeout, "taskTimeout", taskTimeout, "超时时间 单位 秒,默认3600")
+	flag.IntVar(&taskTimeout, "taskTimeout", taskTimeout, "超时时间 单位 秒,默认3600 指定 -duration、-stages 时默认不限制，不能短于压测时长")
+	flag.DurationVar(&duration, "duration", duration, "压测时长 示例:-duration 10m，未指定 -n 时不限制请求数")
+	flag.Float64Var(&rate, "rate", rate, "开放模型 固定到达速率(每秒请求数)，请求总数为 -c * -n，默认0为闭合模型")
+	flag.Uint64Var(&maxInFlight, "maxInFlight", maxInFlight, "开放模型 最大并发请求数，超出的请求计为丢弃，默认为最高到达速率(每秒请求数，响应在1秒以内时不丢弃)，不小于 -c")
+	flag.StringVar(&stages, "stages", stages, "分阶段压测 示例:2m:200,10m:200,1m:0 闭合模型目标值为并发数(从0开始)，开放模型为每秒请求数(从 -rate 开始)")
+	flag.DurationVar(&warmUp, "warmup", warmUp, "预热时长 预热期间的请求不计入统计 示例:-warmup 30s")
+	flag.Uint64Var(&warmUpNum, "warmupNumber", warmUpNum, "预热请求数(所有协程) 与预热时长同时设置时先到先结束")
+	flag.DurationVar(&pacing, "pacing", pacing, "节奏 每个协程每隔该时长发起一次请求，与响应快慢无关 示例:-pacing 5s，webSocket 默认1s")
+	flag.StringVar(&thinkTime, "think", thinkTime, "思考时间 每个协程两次请求之间的等待 示例:1s uniform:500ms:2s normal:1s:200ms exponential:1s")
+	flag.BoolVar(&coCorrect, "co", coCorrect, "输出协调遗漏修正后的耗时 开放模型以计划发起时间计算，闭合模型需要设置 -coInterval")
+	flag.DurationVar(&coInterval, "coInterval", coInterval, "闭合模型 协调遗漏修正的期望请求间隔 示例:-coInterval 10ms")
+	flag.StringVar(&search, "search", search, "容量搜索 起始负载:每级增加:最大负载 示例:10:10:500 闭合模型负载为并发数，开放模型(-rate)为每秒请求数")
+	flag.DurationVar(&searchStep, "searchStep", searchStep, "容量搜索 每级压测时长")
+	flag.StringVar(&searchThresholds, "searchThresholds", searchThresholds, "容量搜索 判定条件 支持:pN avg max min error_rate qps 示例:p99<500ms,error_rate<1%")
+	flag.StringVar(&thresholdExprs, "thresholds", thresholdExprs, "压测结束时的判定条件 不满足时退出码为1 支持:pN avg max min error_rate qps，[接口名称]只判定该接口 示例:p99<300ms,error_rate<0.1%,qps>1000,p99[step1]<200ms")
+	flag.StringVar(&abortExprs, "abort", abortExprs, "提前结束压测的条件 格式:条件[:持续时长]，按每秒统计数据判定，连续满足指定时长后结束压测并输出结果，退出码为1 示例:error_rate>50%:10s,p95>5s:30s")
+	flag.IntVar(&precision, "precision", precision, "耗时百分位的有效数字位数 1~4，位数越多越精确，占用内存越多")
+	flag.StringVar(&percentiles, "percentiles", percentiles, "输出的耗时百分位 示例:50,75,90,99,99.9,99.99")
+	flag.StringVar(&timeSeries, "timeseries", timeSeries, "每秒统计数据导出文件 .csv 为 CSV，.jsonl 为 JSON Lines 示例:-timeseries out.csv")
+	flag.BoolVar(&tui, "tui", tui, "全屏终端面板 显示实时吞吐量、耗时趋势、百分位、错误码、进度，标准输出不是终端时使用逐行输出的表格")
+	flag.StringVar(&reportJSON, "report-json", reportJSON, "JSON 格式压测报告文件 包含请求参数、汇总、错误码、耗时分布、吞吐量 示例:-report-json out.json")
+	flag.StringVar(&reportJUnit, "report-junit", reportJUnit, "JUnit XML 格式压测报告文件 每个接口一个用例，有失败请求时用例失败")
+	flag.StringVar(&reportHTML, "report-html", reportHTML, "HTML 格式压测报告文件 离线可查看，包含吞吐量、耗时百分位随时间变化图、耗时分布、错误码")
+	flag.StringVar(&metricsAddr, "metrics-addr", metricsAddr, "压测过程中提供 Prometheus 指标的监听地址 示例:-metrics-addr :9100 指标地址为 /metrics")
+	flag.StringVar(&statsdAddr, "statsd", statsdAddr, "每秒统计数据推送到 StatsD 示例:-statsd udp://127.0.0.1:8125")
+	flag.StringVar(&influxAddr, "influx", influxAddr, "每秒统计数据以 InfluxDB 行协议推送 示例:-influx udp://127.0.0.1:8089")
+	flag.StringVar(&pushTags, "tags", pushTags, "推送的标签 示例:-tags test=checkout,run=42")
+	flag.StringVar(&resultsLog, "results-log", resultsLog, "请求结果日志文件 每个请求一行，.csv 为 CSV，.jsonl 为 JSON Lines 示例:-results-log results.jsonl")
+	flag.Float64Var(&resultsSample, "results-sample", resultsSample, "请求结果日志 成功请求的采样比例 0~1，失败的请求全部记录 示例:-results-sample 0.01")
 	// 解析参数
 	flag.Parse()
+	// 只指定压测时长或分阶段压测时不限制请求数
+	if (duration > 0 || stages != "") && !isFlagPassed("n") {
+		reqNumbersPerProd = 0
+	}
+}
+
+// isFlagPassed 参数是否在命令行中指定
+func isFlagPassed(name string) (passed bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return
 }
 
 // handle args
 func argsCheck() bool {
-	if concurrency == 0 || reqNumbersPerProd == 0 || (requestURL == "" && curlFilePath == "") {
+	if concurrency == 0 || (reqNumbersPerProd == 0 && duration == 0 && stages == "" && search == "") || (requestURL == "" && curlFilePath == "") {
 		fmt.Printf("示例: go run main.go -c 1 -n 1 -u https://www.baidu.com/ \n")
+		fmt.Printf("示例: go run main.go -c 1 -duration 10m -u https://www.baidu.com/ \n")
 		fmt.Printf("压测地址或curl路径必填 \n")
-		fmt.Printf("当前请求参数: -c %d -n %d -d %v -u %s \n", concurrency, reqNumbersPerProd, debugStr, requestURL)
+		fmt.Printf("当前请求参数: -c %d -n %d -duration %s -d %v -u %s \n", concurrency, reqNumbersPerProd, duration,
+			debugStr, requestURL)
 		flag.Usage()
 		return false
 	}
 	return true
 }
 
+func genTaskForm() *model.TaskForm {
+	task, err := model.NewTaskForm(concurrency, reqNumbersPerProd, duration, rate, maxInFlight, stages)
+	if err == nil {
+		err = task.SetWarmUp(warmUp, warmUpNum)
+	}
+	if err == nil {
+		err = task.SetCoCorrection(coCorrect, coInterval)
+	}
+	if err == nil {
+		err = task.SetPacing(pacing, thinkTime)
+	}
+	if err == nil {
+		err = checkTaskTimeout(task)
+	}
+	if err != nil {
+		fmt.Printf("参数不合法 %v \n", err)
+		return nil
+	}
+	return task
+}
+
+// checkTaskTimeout 按压测时长(包括分阶段压测的总时长)检查任务超时
+// 没有指定 -taskTimeout 时不再限制，以压测时长为准；指定的超时时间短于压测时长时不合法
+func checkTaskTimeout(task *model.TaskForm) error {
+	if task.Duration == 0 || taskTimeout <= 0 {
+		return nil
+	}
+	if !isFlagPassed("taskTimeout") {
+		taskTimeout = 0
+		return nil
+	}
+	if timeout := time.Duration(taskTimeout) * time.Second; timeout < task.Duration {
+		return fmt.Errorf("任务超时时间 %s 短于压测时长 %s", timeout, task.Duration)
+	}
+	return nil
+}
+
+// setupStatistics 设置统计输出参数
+func setupStatistics() bool {
+	statistics.SetDashboard(tui)
+	err := statistics.SetPrecision(precision)
+	if err == nil {
+		err = statistics.SetPercentiles(percentiles)
+	}
+	// 容量搜索使用 -searchThresholds 判定每一级压测
+	if err == nil && search == "" {
+		err = statistics.SetThresholds(thresholdExprs)
+	}
+	if err == nil {
+		err = statistics.SetAbortConditions(abortExprs)
+	}
+	if err == nil && timeSeries != "" {
+		err = statistics.OpenTimeSeries(timeSeries)
+	}
+	if err == nil && (statsdAddr != "" || influxAddr != "") {
+		err = registerPush()
+	}
+	if err == nil && metricsAddr != "" {
+		var collector *metrics.Collector
+		collector, err = metrics.Listen(metricsAddr)
+		if err == nil {
+			server.RegisterObserver(collector)
+		}
+	}
+	if err == nil && resultsLog != "" {
+		resultLog, err = resultlog.Open(resultsLog, resultsSample)
+		if err == nil {
+			server.RegisterObserver(resultLog)
+		}
+	}
+	if err != nil {
+		fmt.Printf("参数不合法 %v \n", err)
+		return false
+	}
+	return true
+}
+
+// registerPush 注册 StatsD、InfluxDB 推送
+func registerPush() error {
+	tags, err := push.ParseTags(pushTags)
+	if err != nil {
+		return err
+	}
+	for format, addr := range map[string]string{push.FormatStatsD: statsdAddr, push.FormatInflux: influxAddr} {
+		if addr == "" {
+			continue
+		}
+		sink, err := push.NewSink(format, addr, tags)
+		if err != nil {
+			return err
+		}
+		statistics.RegisterIntervalSink(sink)
+	}
+	return nil
+}
+
+func genSearchForm(task *model.TaskForm) *model.SearchForm {
+	if search == "" {
+		return nil
+	}
+	form, err := model.NewSearchForm(search, searchStep, searchThresholds)
+	if err == nil {
+		err = form.Check(task)
+	}
+	// 开放模型按搜索的最高速率计算默认的最大并发请求数
+	if err == nil && task.IsOpen() && !isFlagPassed("maxInFlight") {
+		task.MaxInFlight = model.DefaultMaxInFlight(task.Concurrency, form.Max)
+	}
+	if err != nil {
+		fmt.Printf("参数不合法 %v \n", err)
+		return nil
+	}
+	return form
+}
+
 func genRequestForm() *model.RequestForm {
 	debug := strings.ToLower(debugStr) == "true"
 	reqform, err := model.NewReqForm(requestURL, method, verify, statusCode, time.Duration(clientTimeout)*time.Second, debug, curlFilePath, headers, body, maxCon, http2, keepalive)
@@ -87,13 +311,28 @@ func genRequestForm() *model.RequestForm {
 		fmt.Printf("参数不合法 %v \n", err)
 		return nil
 	}
-	fmt.Printf("\n 开始启动  并发数:%d 请求数:%d 请求参数: \n", concurrency, reqNumbersPerProd)
-	reqform.Print()
 	return reqform
 }
 
-func runStress(reqform *model.RequestForm) {
-	ctx := context.Background()
+// handleSignal 第一次收到中断信号时停止压测，等待进行中的请求完成并输出结果，第二次强制退出
+func handleSignal(cancel context.CancelFunc) {
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+	fmt.Printf("\n 收到中断信号，停止压测并输出结果，再次中断强制退出 \n")
+	cancel()
+	<-sig
+	// 全屏面板还在备用屏幕上 先恢复终端
+	statistics.RestoreTerminal()
+	fmt.Printf("\n 强制退出 \n")
+	os.Exit(130)
+}
+
+// runStress 压测 返回压测结果是否通过判定
+func runStress(task *model.TaskForm, searchForm *model.SearchForm, reqform *model.RequestForm) (passed bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handleSignal(cancel)
 	if taskTimeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, time.Duration(taskTimeout)*time.Second)
@@ -103,7 +342,70 @@ func runStress(reqform *model.RequestForm) {
 			fmt.Printf(" deadline %s", deadline)
 		}
 	}
-	server.Dispose(ctx, concurrency, reqNumbersPerProd, reqform)
+	if searchForm != nil {
+		// 没有满足判定条件的负载时视为不通过
+		passed, err := server.Search(ctx, task, searchForm, reqform)
+		if err != nil {
+			fmt.Printf("参数不合法 %v \n", err)
+		}
+		return passed
+	}
+	result := server.Dispose(ctx, task, reqform)
+	writeReports(task, reqform, result)
+	return result.Passed()
+}
+
+// writeReports 输出压测报告
+func writeReports(task *model.TaskForm, reqform *model.RequestForm, result *statistics.Result) {
+	if reportJSON != "" {
+		if err := report.WriteJSON(reportJSON, task, reqform, result); err != nil {
+			fmt.Printf("JSON 报告输出失败 %v \n", err)
+		}
+	}
+	if reportJUnit != "" {
+		if err := report.WriteJUnit(reportJUnit, reqform, result); err != nil {
+			fmt.Printf("JUnit 报告输出失败 %v \n", err)
+		}
+	}
+	if reportHTML != "" {
+		if err := report.WriteHTML(reportHTML, task, reqform, result); err != nil {
+			fmt.Printf("HTML 报告输出失败 %v \n", err)
+		}
+	}
+}
+
+// runCompare 对比基线和本次压测的 JSON 报告 返回退出码 0:没有回退 1:有回退 2:参数错误
+// 示例: goapistress compare -latency 10 base.json current.json
+func runCompare(args []string) int {
+	var tolerance report.Tolerance
+	flags := flag.NewFlagSet("compare", flag.ContinueOnError)
+	flags.Float64Var(&tolerance.QPS, "qps", 5, "qps 允许下降的百分比")
+	flags.Float64Var(&tolerance.ErrorRate, "error-rate", 0.1, "错误率允许上升的百分点")
+	flags.Float64Var(&tolerance.Latency, "latency", 10, "耗时百分位允许上升的百分比")
+	flags.Float64Var(&tolerance.Confidence, "confidence", 0.95, "显著性检验的置信度 超出容差且变化显著时判定为回退")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "用法: goapistress compare [参数] 基线.json 本次.json")
+		fmt.Fprintln(flags.Output(), "对比两次 -report-json 输出的报告，有回退时退出码为1")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+	regressed, err := report.Compare(os.Stdout, flags.Arg(0), flags.Arg(1), tolerance)
+	if err != nil {
+		fmt.Printf("对比失败 %v \n", err)
+		return 2
+	}
+	if regressed {
+		fmt.Println("\n结论: 有回退")
+		return 1
+	}
+	fmt.Println("\n结论: 没有回退")
+	return 0
 }
 
 // main go 实现的压测工具
@@ -113,17 +415,55 @@ func runStress(reqform *model.RequestForm) {
 func main() {
 	runtime.GOMAXPROCS(cpuNumber)
 
+	// 对比两次压测结果
+	if flag.Arg(0) == "compare" {
+		os.Exit(runCompare(flag.Args()[1:]))
+	}
+
 	// args check
 	if !argsCheck() {
 		return
 	}
 
+	// gen task
+	task := genTaskForm()
+	if task == nil {
+		return
+	}
+
+	// statistics
+	if !setupStatistics() {
+		return
+	}
+
+	// gen search
+	searchForm := genSearchForm(task)
+	if search != "" && searchForm == nil {
+		return
+	}
+
 	// gen requester
 	reqForm := genRequestForm()
 	if reqForm == nil {
 		return
 	}
+	if searchForm == nil {
+		task.Print()
+	}
+	reqForm.Print()
 
 	// 开始处理
-	runStress(reqForm)
+	passed := runStress(task, searchForm, reqForm)
+	if err := statistics.CloseIntervalSinks(); err != nil {
+		fmt.Printf("统计数据输出失败 %v \n", err)
+	}
+	if resultLog != nil {
+		if err := resultLog.Close(); err != nil {
+			fmt.Printf("请求结果日志输出失败 %v \n", err)
+		}
+	}
+	// 判定条件不满足、请求全部失败、容量搜索没有满足条件的负载时退出码为1，便于在 CI 中使用
+	if !passed {
+		os.Exit(1)
+	}
 }
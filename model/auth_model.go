@@ -18,7 +18,7 @@ func computeSha256(str string) string {
 }
 
 func setAuth(req *http.Request, apiAccount string, apiToken string, reqPath string) error {
-	fmt.Println("===setAuth reqPath: %s", reqPath)
+	fmt.Printf("===setAuth reqPath: %s\n", reqPath)
 	localTimeStamp := time.Now().Unix()
 	// t, _ := time.ParseInLocation("2022-10-08 15:23:00", time.Now().Format("2006-01-02 15:04:05"), time.Local)
 	// localTimeStamp := t.Unix()
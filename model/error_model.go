@@ -0,0 +1,63 @@
+// Package model 数据模型
+package model
+
+import (
+	"fmt"
+)
+
+// 请求失败的错误分类 错误码固定，不与 http 状态码冲突
+const (
+	// ErrTimeout 超时
+	ErrTimeout = 601
+	// ErrConnRefused 连接被拒绝
+	ErrConnRefused = 602
+	// ErrConnReset 连接被重置
+	ErrConnReset = 603
+	// ErrDNS DNS 解析失败
+	ErrDNS = 604
+	// ErrTLS TLS 握手、证书错误
+	ErrTLS = 605
+	// ErrEOF 连接意外关闭
+	ErrEOF = 606
+	// ErrCanceled 压测结束、被中断时取消的请求
+	ErrCanceled = 607
+	// ErrGRPCBase gRPC 状态码错误 错误码为 ErrGRPCBase + gRPC 状态码
+	ErrGRPCBase = 700
+)
+
+// errNames 错误码名称
+var errNames = map[int]string{
+	RequestErr:     "request_error",
+	ParseError:     "parse_error",
+	ErrTimeout:     "timeout",
+	ErrConnRefused: "connection_refused",
+	ErrConnReset:   "connection_reset",
+	ErrDNS:         "dns_failure",
+	ErrTLS:         "tls_error",
+	ErrEOF:         "eof",
+	ErrCanceled:    "context_cancelled",
+}
+
+// grpcNames gRPC 状态码名称
+var grpcNames = []string{"ok", "canceled", "unknown", "invalid_argument", "deadline_exceeded", "not_found",
+	"already_exists", "permission_denied", "resource_exhausted", "failed_precondition", "aborted", "out_of_range",
+	"unimplemented", "internal", "unavailable", "data_loss", "unauthenticated"}
+
+// ErrName 错误码名称 http 状态码等没有名称的错误码返回空
+func ErrName(code int) string {
+	if name, ok := errNames[code]; ok {
+		return name
+	}
+	if code > ErrGRPCBase && code < ErrGRPCBase+len(grpcNames) {
+		return "grpc_" + grpcNames[code-ErrGRPCBase]
+	}
+	return ""
+}
+
+// ErrCodeString 错误码和名称 示例: 601(timeout)
+func ErrCodeString(code int) string {
+	if name := ErrName(code); name != "" {
+		return fmt.Sprintf("%d(%s)", code, name)
+	}
+	return fmt.Sprintf("%d", code)
+}
@@ -75,6 +75,7 @@ type VerifyWebSocket func(request *RequestForm, seq string, msg []byte) (code in
 
 // RequestForm 请求数据
 type RequestForm struct {
+	Name          string            // 接口名称 分接口统计，为空时使用 方法+URL
 	URL           string            // URL
 	MP            string            // http/webSocket/tcp
 	Method        string            // 方法 GET/POST/PUT
@@ -222,12 +223,28 @@ func (r *RequestForm) Print() {
 	if r == nil {
 		return
 	}
+	fmt.Println(r.String())
+}
+
+// String 格式化
+func (r *RequestForm) String() string {
 	result := fmt.Sprintf("request:\n mainprotocol:%s \n url:%s \n method:%s \n headers:%v \n", r.MP, r.URL, r.Method,
 		r.Headers)
 	result = fmt.Sprintf("%s data:%v \n", result, r.Body)
 	result = fmt.Sprintf("%s verify:%s \n clienttimeout:%s \n debug:%v \n", result, r.Verify, r.ClientTimeout, r.Debug)
 	result = fmt.Sprintf("%s http2.0:%v \n keepalive:%v \n maxCon:%v ", result, r.HTTP2, r.Keepalive, r.MaxCon)
-	fmt.Println(result)
+	return result
+}
+
+// GetName 接口名称 分接口统计
+func (r *RequestForm) GetName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	if r.Method == "" || r.MP != MPTypeHTTP {
+		return r.URL
+	}
+	return r.Method + " " + r.URL
 }
 
 // GetDebug 获取 debug 参数
@@ -250,12 +267,29 @@ func (r *RequestForm) IsParameterLegal() (err error) {
 
 // RequestResults 请求结果
 type RequestResults struct {
-	ID            string // 消息ID
-	ChanID        uint64 // 消息ID
-	Time          uint64 // 请求时间 纳秒
-	IsSucceed     bool   // 是否请求成功
-	ErrCode       int    // 错误码
+	ID            string    // 消息ID
+	ChanID        uint64    // 消息ID
+	StartTime     time.Time // 请求发起时间
+	Time          uint64    // 请求时间 纳秒
+	Delay         uint64    // 实际发起时间比计划发起时间晚的时长 纳秒，用于协调遗漏修正
+	IsSucceed     bool      // 是否请求成功
+	WarmUp        bool      // 是否为预热阶段的请求 不计入统计
+	ErrCode       int       // 错误码
+	ErrMsg        string    // 请求失败时的原始错误信息
 	ReceivedBytes int64
+	SentBytes     int64             // 上传字节 http 为请求行、头和 body，其它协议为发送的消息
+	Endpoint      string            // 接口名称 分接口统计
+	Steps         []*RequestResults // 分步压测 每一步的结果，Time 为各步耗时之和
+	Phases        *Phases           // HTTP 请求各阶段耗时 其它协议为 nil
+}
+
+// Phases HTTP 请求各阶段耗时 纳秒，复用连接时没有 DNS、连接、TLS 阶段(为0)
+type Phases struct {
+	DNS      uint64 // DNS 解析
+	Connect  uint64 // TCP 连接
+	TLS      uint64 // TLS 握手
+	TTFB     uint64 // 首字节 请求发送完成到收到响应的第一个字节
+	Download uint64 // 下载 读取响应 body
 }
 
 // SetID 设置请求唯一ID
@@ -0,0 +1,98 @@
+// Package model 数据模型
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SearchForm 容量搜索参数 逐级增加负载，直到不满足判定条件
+// 闭合模型负载为并发数，开放模型为每秒请求数
+type SearchForm struct {
+	Start        float64       // 起始负载
+	Step         float64       // 每级增加的负载
+	Max          float64       // 最大负载
+	StepDuration time.Duration // 每级压测时长
+	Thresholds   string        // 判定条件 示例:p99<500ms,error_rate<1%
+}
+
+// NewSearchForm 生成容量搜索参数
+// search 起始负载:每级增加:最大负载 示例:10:10:500
+func NewSearchForm(search string, stepDuration time.Duration, thresholds string) (form *SearchForm, err error) {
+	arr := strings.Split(search, ":")
+	if len(arr) != 3 {
+		err = fmt.Errorf("容量搜索参数不合法:%s 示例:10:10:500", search)
+		return
+	}
+	values := make([]float64, len(arr))
+	for i, str := range arr {
+		values[i], err = strconv.ParseFloat(strings.TrimSpace(str), 64)
+		if err != nil || values[i] <= 0 {
+			err = fmt.Errorf("容量搜索参数不合法:%s 示例:10:10:500", search)
+			return
+		}
+	}
+	if values[0] > values[2] {
+		err = fmt.Errorf("容量搜索起始负载不能大于最大负载:%s", search)
+		return
+	}
+	if stepDuration <= 0 {
+		err = fmt.Errorf("容量搜索每级压测时长不合法:%s", stepDuration)
+		return
+	}
+	form = &SearchForm{
+		Start:        values[0],
+		Step:         values[1],
+		Max:          values[2],
+		StepDuration: stepDuration,
+		Thresholds:   thresholds,
+	}
+	return
+}
+
+// Check 检查与压测任务参数是否冲突
+// 每级压测都有预热，预热时长必须小于每级压测时长；闭合模型负载为并发数，起始负载不能小于1
+func (s *SearchForm) Check(task *TaskForm) error {
+	if task.WarmUp >= s.StepDuration {
+		return fmt.Errorf("预热时长:%s 必须小于容量搜索每级压测时长:%s", task.WarmUp, s.StepDuration)
+	}
+	if !task.IsOpen() && s.Start < 1 {
+		return fmt.Errorf("闭合模型容量搜索负载为并发数，起始负载不能小于1:%v", s.Start)
+	}
+	return nil
+}
+
+// Levels 每级的负载
+func (s *SearchForm) Levels() (levels []float64) {
+	for level := s.Start; level <= s.Max; level = level + s.Step {
+		levels = append(levels, level)
+	}
+	return
+}
+
+// StepTask 生成某一级的压测任务参数
+func (s *SearchForm) StepTask(task *TaskForm, level float64) *TaskForm {
+	step := &TaskForm{
+		Concurrency:  uint64(level),
+		Duration:     s.StepDuration,
+		MaxInFlight:  task.MaxInFlight,
+		WarmUp:       task.WarmUp,
+		WarmUpNumber: task.WarmUpNumber,
+		CoCorrect:    task.CoCorrect,
+		CoInterval:   task.CoInterval,
+	}
+	if task.IsOpen() {
+		step.Concurrency = task.Concurrency
+		step.Rate = level
+	} else {
+		// 并发数至少为1 避免没有协程时空跑一级
+		if step.Concurrency == 0 {
+			step.Concurrency = 1
+		}
+		step.Pacing = task.Pacing
+		step.ThinkTime = task.ThinkTime
+	}
+	return step
+}
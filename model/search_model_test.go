@@ -0,0 +1,38 @@
+// Package model 数据模型
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSearchFormCheck 测试预热时长、闭合模型起始负载的检查
+func TestSearchFormCheck(t *testing.T) {
+	tt := map[string]struct {
+		search string
+		rate   float64
+		warmUp time.Duration
+		ok     bool
+	}{
+		"ok":         {search: "10:10:50", warmUp: 5 * time.Second, ok: true},
+		"warmUp":     {search: "10:10:50", warmUp: 10 * time.Second},
+		"fraction":   {search: "0.5:1:5"},
+		"openModel":  {search: "0.5:1:5", rate: 1, ok: true},
+		"startEqual": {search: "1:1:5", ok: true},
+	}
+	for name, value := range tt {
+		form, err := NewSearchForm(value.search, 10*time.Second, "p99<1s")
+		if err != nil {
+			t.Fatalf("%s 参数解析失败:%v", name, err)
+		}
+		task := &TaskForm{Concurrency: 1, Rate: value.rate, WarmUp: value.warmUp}
+		if err = form.Check(task); (err == nil) != value.ok {
+			t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, value.ok, err)
+		}
+	}
+	// 闭合模型并发数至少为1
+	form := &SearchForm{StepDuration: time.Second}
+	if step := form.StepTask(&TaskForm{Concurrency: 1}, 0.5); step.Concurrency != 1 {
+		t.Errorf("数据不一致 预期:%v 实际:%v", 1, step.Concurrency)
+	}
+}
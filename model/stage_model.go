@@ -0,0 +1,91 @@
+// Package model 数据模型
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stage 压测阶段 在 Duration 时长内由上一阶段的目标值线性变化到 Target
+type Stage struct {
+	Duration time.Duration // 阶段时长
+	Target   float64       // 阶段结束时的目标值 闭合模型为并发数，开放模型为每秒请求数
+}
+
+// Stages 分阶段压测
+type Stages []Stage
+
+// ParseStages 解析阶段参数
+// 示例: 2m:200,10m:200,1m:0 表示 2分钟内增加到200，保持10分钟，1分钟内降到0
+func ParseStages(str string) (stages Stages, err error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return
+	}
+	for _, item := range strings.Split(str, ",") {
+		index := strings.Index(item, ":")
+		if index < 0 {
+			err = fmt.Errorf("阶段参数不合法:%s 示例:2m:200", item)
+			return
+		}
+		var stage Stage
+		stage.Duration, err = time.ParseDuration(strings.TrimSpace(item[:index]))
+		if err != nil || stage.Duration < 0 {
+			err = fmt.Errorf("阶段时长不合法:%s", item)
+			return
+		}
+		stage.Target, err = strconv.ParseFloat(strings.TrimSpace(item[index+1:]), 64)
+		if err != nil || stage.Target < 0 {
+			err = fmt.Errorf("阶段目标值不合法:%s", item)
+			return
+		}
+		stages = append(stages, stage)
+	}
+	return
+}
+
+// TotalDuration 所有阶段的总时长
+func (s Stages) TotalDuration() (total time.Duration) {
+	for _, stage := range s {
+		total = total + stage.Duration
+	}
+	return
+}
+
+// MaxTarget 所有阶段中最大的目标值
+func (s Stages) MaxTarget(start float64) (max float64) {
+	max = start
+	for _, stage := range s {
+		if stage.Target > max {
+			max = stage.Target
+		}
+	}
+	return
+}
+
+// Value 压测进行 elapsed 时长时的目标值和所处阶段(从0开始)
+// start 第一个阶段开始时的值
+func (s Stages) Value(start float64, elapsed time.Duration) (value float64, index int) {
+	value = start
+	for i, stage := range s {
+		if elapsed < stage.Duration {
+			value = value + (stage.Target-value)*float64(elapsed)/float64(stage.Duration)
+			return value, i
+		}
+		elapsed = elapsed - stage.Duration
+		value = stage.Target
+		index = i
+	}
+	return
+}
+
+// String 格式化
+func (s Stages) String() string {
+	var arr []string
+	for _, stage := range s {
+		arr = append(arr, fmt.Sprintf("%s:%v", stage.Duration, stage.Target))
+	}
+	return strings.Join(arr, ",")
+}
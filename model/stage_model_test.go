@@ -0,0 +1,58 @@
+// Package model 数据模型
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseStages 测试阶段参数解析
+func TestParseStages(t *testing.T) {
+	tt := map[string]struct {
+		str    string
+		stages Stages
+		isErr  bool
+	}{
+		"empty":   {str: "", stages: nil},
+		"stages":  {str: "2m:200, 10m:200,1m:0", stages: Stages{{2 * time.Minute, 200}, {10 * time.Minute, 200}, {time.Minute, 0}}},
+		"noColon": {str: "2m", isErr: true},
+		"badTime": {str: "2x:200", isErr: true},
+		"badNum":  {str: "2m:-1", isErr: true},
+	}
+	for name, value := range tt {
+		stages, err := ParseStages(value.str)
+		if (err != nil) != value.isErr {
+			t.Errorf("%s 错误不一致 预期:%v 实际:%v", name, value.isErr, err)
+			continue
+		}
+		if stages.String() != value.stages.String() {
+			t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, value.stages, stages)
+		}
+	}
+}
+
+// TestStagesValue 测试阶段目标值
+func TestStagesValue(t *testing.T) {
+	stages := Stages{{2 * time.Minute, 200}, {10 * time.Minute, 200}, {0, 50}, {time.Minute, 0}}
+	tt := map[string]struct {
+		elapsed time.Duration
+		value   float64
+		index   int
+	}{
+		"start":    {elapsed: 0, value: 0, index: 0},
+		"rampUp":   {elapsed: time.Minute, value: 100, index: 0},
+		"hold":     {elapsed: 5 * time.Minute, value: 200, index: 1},
+		"jump":     {elapsed: 12 * time.Minute, value: 50, index: 3},
+		"rampDown": {elapsed: 12*time.Minute + 30*time.Second, value: 25, index: 3},
+		"end":      {elapsed: time.Hour, value: 0, index: 3},
+	}
+	for name, value := range tt {
+		v, index := stages.Value(0, value.elapsed)
+		if v != value.value || index != value.index {
+			t.Errorf("%s 数据不一致 预期:%v,%d 实际:%v,%d", name, value.value, value.index, v, index)
+		}
+	}
+	if stages.TotalDuration() != 13*time.Minute {
+		t.Errorf("总时长不一致 实际:%s", stages.TotalDuration())
+	}
+}
@@ -0,0 +1,333 @@
+// Package model 数据模型
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TaskForm 压测任务参数
+type TaskForm struct {
+	Concurrency  uint64        // 并发数
+	Number       uint64        // 请求数(单个并发/协程) 0:不限制，以压测时长为准
+	Duration     time.Duration // 压测时长 0:不限制，以请求数为准
+	Rate         float64       // 开放模型 固定到达速率(每秒请求数) 0:闭合模型
+	MaxInFlight  uint64        // 开放模型 最大并发请求数
+	Stages       Stages        // 分阶段压测 闭合模型目标值为并发数，开放模型为每秒请求数
+	WarmUp       time.Duration // 预热时长 预热期间的请求不计入统计
+	WarmUpNumber uint64        // 预热请求数(所有协程) 与预热时长同时设置时先到先结束
+	CoCorrect    bool          // 是否输出协调遗漏修正后的耗时
+	CoInterval   time.Duration // 闭合模型 协调遗漏修正的期望请求间隔
+	Pacing       time.Duration // 闭合模型 每个协程两次请求发起的间隔，与响应快慢无关
+	ThinkTime    *ThinkTime    // 闭合模型 每个协程两次请求之间的思考时间
+}
+
+// NewTaskForm 生成压测任务参数
+// concurrency 并发数
+// number 请求数(单个并发/协程)
+// duration 压测时长，与请求数同时设置时先到先结束
+// rate 开放模型每秒请求数，请求总数为 concurrency*number
+// maxInFlight 开放模型最大并发请求数 0:按最高到达速率计算，见 DefaultMaxInFlight
+// stages 分阶段压测 示例:2m:200,10m:200,1m:0 闭合模型从0个并发开始，开放模型从 rate 开始
+func NewTaskForm(concurrency, number uint64, duration time.Duration, rate float64,
+	maxInFlight uint64, stages string) (task *TaskForm, err error) {
+	if concurrency == 0 {
+		err = errors.New("并发数必须大于0")
+		return
+	}
+	if duration < 0 {
+		err = fmt.Errorf("压测时长不合法:%s", duration)
+		return
+	}
+	stageList, err := ParseStages(stages)
+	if err != nil {
+		return
+	}
+	// 分阶段压测以所有阶段的总时长为压测时长
+	if total := stageList.TotalDuration(); total > 0 && (duration == 0 || duration > total) {
+		duration = total
+	}
+	if number == 0 && duration == 0 {
+		err = errors.New("请求数和压测时长不能同时为0")
+		return
+	}
+	if rate < 0 {
+		err = fmt.Errorf("到达速率不合法:%v", rate)
+		return
+	}
+	if maxInFlight == 0 {
+		maxInFlight = concurrency
+		if rate > 0 {
+			maxInFlight = DefaultMaxInFlight(concurrency, stageList.MaxTarget(rate))
+		}
+	}
+	task = &TaskForm{
+		Concurrency: concurrency,
+		Number:      number,
+		Duration:    duration,
+		Rate:        rate,
+		MaxInFlight: maxInFlight,
+		Stages:      stageList,
+	}
+	return
+}
+
+// DefaultMaxInFlight 开放模型默认的最大并发请求数
+// 按最高到达速率 peakRate 计算，响应在1秒以内时不丢弃请求，不小于 concurrency
+func DefaultMaxInFlight(concurrency uint64, peakRate float64) uint64 {
+	if n := uint64(math.Ceil(peakRate)); n > concurrency {
+		return n
+	}
+	return concurrency
+}
+
+// SetWarmUp 设置预热 按时长或请求数预热，同时设置时先到先结束
+// 预热包含在压测时长、请求数之内
+func (t *TaskForm) SetWarmUp(duration time.Duration, number uint64) (err error) {
+	if duration < 0 {
+		return fmt.Errorf("预热时长不合法:%s", duration)
+	}
+	if t.Duration > 0 && duration >= t.Duration {
+		return fmt.Errorf("预热时长:%s 必须小于压测时长:%s", duration, t.Duration)
+	}
+	t.WarmUp = duration
+	t.WarmUpNumber = number
+	return
+}
+
+// SetCoCorrection 设置协调遗漏修正
+// 开放模型以计划发起时间计算耗时，闭合模型没有计划发起时间，按期望请求间隔 interval 补齐被阻塞的请求
+func (t *TaskForm) SetCoCorrection(enable bool, interval time.Duration) (err error) {
+	if !enable {
+		return
+	}
+	if interval < 0 {
+		return fmt.Errorf("协调遗漏修正期望请求间隔不合法:%s", interval)
+	}
+	if !t.IsOpen() && interval == 0 {
+		return errors.New("闭合模型协调遗漏修正需要设置期望请求间隔")
+	}
+	t.CoCorrect = true
+	t.CoInterval = interval
+	return
+}
+
+// SetPacing 设置闭合模型的节奏和思考时间
+// pacing 每个协程每 pacing 时长发起一次请求，响应超过 pacing 时立即发起下一次
+// think 思考时间 上一次请求完成以后等待的时长
+func (t *TaskForm) SetPacing(pacing time.Duration, think string) (err error) {
+	thinkTime, err := ParseThinkTime(think)
+	if err != nil {
+		return
+	}
+	if pacing < 0 {
+		return fmt.Errorf("节奏不合法:%s", pacing)
+	}
+	if t.IsOpen() && (pacing > 0 || thinkTime != nil) {
+		return errors.New("开放模型按到达速率发起请求，不支持节奏和思考时间")
+	}
+	t.Pacing = pacing
+	t.ThinkTime = thinkTime
+	return
+}
+
+// IsStaged 是否为分阶段压测
+func (t *TaskForm) IsStaged() bool {
+	return len(t.Stages) > 0
+}
+
+// Target 压测进行 elapsed 时长时的目标值和所处阶段
+// 闭合模型目标值为并发数，开放模型为每秒请求数
+func (t *TaskForm) Target(elapsed time.Duration) (value float64, stage int) {
+	if !t.IsStaged() {
+		if t.IsOpen() {
+			return t.Rate, 0
+		}
+		return float64(t.Concurrency), 0
+	}
+	if t.IsOpen() {
+		return t.Stages.Value(t.Rate, elapsed)
+	}
+	return t.Stages.Value(0, elapsed)
+}
+
+// IsOpen 是否为开放模型(固定到达速率)
+func (t *TaskForm) IsOpen() bool {
+	return t.Rate > 0
+}
+
+// Workers 启动的协程数 开放模型为最大并发请求数
+func (t *TaskForm) Workers() uint64 {
+	if t.IsOpen() {
+		return t.MaxInFlight
+	}
+	if t.IsStaged() {
+		return uint64(math.Ceil(t.Stages.MaxTarget(0)))
+	}
+	return t.Concurrency
+}
+
+// Print 格式化打印
+func (t *TaskForm) Print() {
+	if t == nil {
+		return
+	}
+	number := "不限制"
+	if t.Number > 0 && t.IsOpen() {
+		number = fmt.Sprintf("%d", t.Concurrency*t.Number)
+	} else if t.Number > 0 {
+		number = fmt.Sprintf("%d", t.Number)
+	}
+	duration := "不限制"
+	if t.Duration > 0 {
+		duration = t.Duration.String()
+	}
+	if t.IsStaged() {
+		fmt.Printf("\n 分阶段压测 阶段:%s \n", t.Stages)
+	}
+	t.printWarmUp()
+	if t.Pacing > 0 || t.ThinkTime != nil {
+		fmt.Printf("\n 节奏:%s 思考时间:%s \n", t.Pacing, t.ThinkTime)
+	}
+	if t.IsOpen() {
+		fmt.Printf("\n 开始启动  到达速率:%.2f/s 最大并发:%d 请求总数:%s 压测时长:%s 请求参数: \n", t.Rate,
+			t.MaxInFlight, number, duration)
+		return
+	}
+	fmt.Printf("\n 开始启动  并发数:%d 请求数:%s 压测时长:%s 请求参数: \n", t.Concurrency, number, duration)
+}
+
+// printWarmUp 打印预热参数
+func (t *TaskForm) printWarmUp() {
+	switch {
+	case t.WarmUp > 0 && t.WarmUpNumber > 0:
+		fmt.Printf("\n 预热(不计入统计) 时长:%s 请求数:%d 先到先结束 \n", t.WarmUp, t.WarmUpNumber)
+	case t.WarmUp > 0:
+		fmt.Printf("\n 预热(不计入统计) 时长:%s \n", t.WarmUp)
+	case t.WarmUpNumber > 0:
+		fmt.Printf("\n 预热(不计入统计) 请求数:%d \n", t.WarmUpNumber)
+	}
+}
+
+// TaskStatus 压测运行状态 压测调度时写入，统计输出时读取
+type TaskStatus struct {
+	dropped uint64 // 开放模型下没有空闲协程而丢弃的请求数
+	workers int64  // 分阶段压测 当前运行的协程数
+	stage   int64  // 分阶段压测 当前阶段(从0开始)
+	target  uint64 // 分阶段压测 当前目标值 float64 bits
+
+	mutex       sync.Mutex
+	cancel      context.CancelFunc // 提前结束压测
+	abortReason string             // 提前结束的原因
+	stopReason  string             // 压测结束的原因 统计输出结束以后输出
+}
+
+// SetStage 设置当前阶段和目标值
+func (s *TaskStatus) SetStage(stage int, target float64) {
+	if s == nil {
+		return
+	}
+	atomic.StoreInt64(&s.stage, int64(stage))
+	atomic.StoreUint64(&s.target, math.Float64bits(target))
+}
+
+// GetStage 获取当前阶段和目标值
+func (s *TaskStatus) GetStage() (stage int, target float64) {
+	if s == nil {
+		return
+	}
+	return int(atomic.LoadInt64(&s.stage)), math.Float64frombits(atomic.LoadUint64(&s.target))
+}
+
+// SetWorkers 设置当前运行的协程数
+func (s *TaskStatus) SetWorkers(n int) {
+	if s == nil {
+		return
+	}
+	atomic.StoreInt64(&s.workers, int64(n))
+}
+
+// GetWorkers 获取当前运行的协程数
+func (s *TaskStatus) GetWorkers() int {
+	if s == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&s.workers))
+}
+
+// AddDropped 增加丢弃的请求数
+func (s *TaskStatus) AddDropped(n uint64) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.dropped, n)
+}
+
+// GetDropped 获取丢弃的请求数
+func (s *TaskStatus) GetDropped() uint64 {
+	if s == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// SetCancel 设置提前结束压测的函数
+func (s *TaskStatus) SetCancel(cancel context.CancelFunc) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cancel = cancel
+}
+
+// Abort 提前结束压测 只记录第一次的原因
+func (s *TaskStatus) Abort(reason string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.abortReason != "" {
+		return
+	}
+	s.abortReason = reason
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// GetAbortReason 获取提前结束的原因 未提前结束时为空
+func (s *TaskStatus) GetAbortReason() string {
+	if s == nil {
+		return ""
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.abortReason
+}
+
+// SetStopReason 设置压测结束的原因
+func (s *TaskStatus) SetStopReason(reason string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stopReason = reason
+}
+
+// GetStopReason 获取压测结束的原因 全部请求完成时为空
+func (s *TaskStatus) GetStopReason() string {
+	if s == nil {
+		return ""
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stopReason
+}
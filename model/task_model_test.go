@@ -0,0 +1,34 @@
+// Package model 数据模型
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+// TestNewTaskFormMaxInFlight 测试开放模型默认的最大并发请求数 按最高到达速率计算
+func TestNewTaskFormMaxInFlight(t *testing.T) {
+	tt := map[string]struct {
+		concurrency uint64
+		rate        float64
+		maxInFlight uint64
+		stages      string
+		want        uint64
+	}{
+		"closed":   {concurrency: 10, want: 10},
+		"rate":     {concurrency: 1, rate: 2000, want: 2000},
+		"fraction": {concurrency: 1, rate: 0.5, want: 1},
+		"minimum":  {concurrency: 50, rate: 20, want: 50},
+		"stages":   {concurrency: 1, rate: 10, stages: "1m:100,1m:500,1m:0", want: 500},
+		"explicit": {concurrency: 1, rate: 2000, maxInFlight: 8, want: 8},
+	}
+	for name, value := range tt {
+		task, err := NewTaskForm(value.concurrency, 0, time.Minute, value.rate, value.maxInFlight, value.stages)
+		if err != nil {
+			t.Fatalf("%s 参数不合法:%v", name, err)
+		}
+		if task.MaxInFlight != value.want {
+			t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, value.want, task.MaxInFlight)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Package model 数据模型
+package model
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// 思考时间分布
+const (
+	// ThinkConstant 固定时长
+	ThinkConstant = "constant"
+	// ThinkUniform 均匀分布
+	ThinkUniform = "uniform"
+	// ThinkNormal 正态分布
+	ThinkNormal = "normal"
+	// ThinkExponential 指数分布
+	ThinkExponential = "exponential"
+)
+
+// ThinkTime 思考时间 每个协程两次请求之间的等待时长，模拟用户行为
+type ThinkTime struct {
+	Distribution string          // 分布 constant uniform normal exponential
+	Params       []time.Duration // 分布参数
+}
+
+// ParseThinkTime 解析思考时间
+// 示例: 1s、constant:1s、uniform:500ms:2s(最小:最大)、normal:1s:200ms(均值:标准差)、exponential:1s(均值)
+func ParseThinkTime(str string) (think *ThinkTime, err error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return
+	}
+	arr := strings.Split(str, ":")
+	think = &ThinkTime{
+		Distribution: strings.ToLower(arr[0]),
+	}
+	// 只有时长时为固定时长
+	if _, parseErr := time.ParseDuration(arr[0]); parseErr == nil {
+		think.Distribution = ThinkConstant
+	} else {
+		arr = arr[1:]
+	}
+	for _, value := range arr {
+		var d time.Duration
+		d, err = time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("思考时间不合法:%s", str)
+		}
+		think.Params = append(think.Params, d)
+	}
+	var params int
+	switch think.Distribution {
+	case ThinkConstant, ThinkExponential:
+		params = 1
+	case ThinkUniform, ThinkNormal:
+		params = 2
+	default:
+		return nil, fmt.Errorf("思考时间分布不支持:%s 支持:constant uniform normal exponential", think.Distribution)
+	}
+	if len(think.Params) != params {
+		return nil, fmt.Errorf("思考时间参数不合法:%s 示例:1s uniform:500ms:2s normal:1s:200ms exponential:1s", str)
+	}
+	if think.Distribution == ThinkUniform && think.Params[0] > think.Params[1] {
+		return nil, fmt.Errorf("思考时间最小值不能大于最大值:%s", str)
+	}
+	return
+}
+
+// Duration 按分布生成一次思考时间
+func (t *ThinkTime) Duration(r *rand.Rand) (d time.Duration) {
+	if t == nil {
+		return
+	}
+	switch t.Distribution {
+	case ThinkConstant:
+		d = t.Params[0]
+	case ThinkUniform:
+		d = t.Params[0] + time.Duration(r.Int63n(int64(t.Params[1]-t.Params[0])+1))
+	case ThinkNormal:
+		d = t.Params[0] + time.Duration(r.NormFloat64()*float64(t.Params[1]))
+	case ThinkExponential:
+		d = time.Duration(r.ExpFloat64() * float64(t.Params[0]))
+	}
+	if d < 0 {
+		d = 0
+	}
+	return
+}
+
+// String 格式化
+func (t *ThinkTime) String() string {
+	if t == nil {
+		return ""
+	}
+	arr := []string{t.Distribution}
+	for _, param := range t.Params {
+		arr = append(arr, param.String())
+	}
+	return strings.Join(arr, ":")
+}
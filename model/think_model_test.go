@@ -0,0 +1,52 @@
+// Package model 数据模型
+package model
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// TestParseThinkTime 测试思考时间解析
+func TestParseThinkTime(t *testing.T) {
+	tt := map[string]struct {
+		str   string
+		think string
+		isErr bool
+	}{
+		"empty":       {str: "", think: ""},
+		"duration":    {str: "1s", think: "constant:1s"},
+		"constant":    {str: "constant:500ms", think: "constant:500ms"},
+		"uniform":     {str: "uniform:500ms:2s", think: "uniform:500ms:2s"},
+		"normal":      {str: "normal:1s:200ms", think: "normal:1s:200ms"},
+		"exponential": {str: "exponential:1s", think: "exponential:1s"},
+		"badParams":   {str: "uniform:1s", isErr: true},
+		"badRange":    {str: "uniform:2s:1s", isErr: true},
+		"badType":     {str: "poisson:1s", isErr: true},
+	}
+	for name, value := range tt {
+		think, err := ParseThinkTime(value.str)
+		if (err != nil) != value.isErr {
+			t.Errorf("%s 错误不一致 预期:%v 实际:%v", name, value.isErr, err)
+			continue
+		}
+		if !value.isErr && think.String() != value.think {
+			t.Errorf("%s 数据不一致 预期:%s 实际:%s", name, value.think, think)
+		}
+	}
+}
+
+// TestThinkTimeDuration 测试思考时间的取值范围
+func TestThinkTimeDuration(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	uniform, _ := ParseThinkTime("uniform:500ms:2s")
+	normal, _ := ParseThinkTime("normal:10ms:100ms")
+	for i := 0; i < 1000; i++ {
+		if d := uniform.Duration(r); d < 500*time.Millisecond || d > 2*time.Second {
+			t.Fatalf("均匀分布超出范围:%s", d)
+		}
+		if d := normal.Duration(r); d < 0 {
+			t.Fatalf("正态分布小于0:%s", d)
+		}
+	}
+}
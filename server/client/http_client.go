@@ -3,9 +3,7 @@ package client
 
 import (
 	"crypto/tls"
-	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"goapistress/model"
@@ -15,16 +13,15 @@ import (
 	"golang.org/x/net/http2"
 )
 
-// logErr err
-var logErr = log.New(os.Stderr, "", 0)
-
 // HTTPRequest HTTP 请求
 // method 方法 GET POST
 // url 请求的url
 // body 请求的body
 // headers 请求头信息
 // timeout 请求超时时间
-func HTTPRequest(chanID uint64, request *model.RequestForm) (resp *http.Response, requestTime uint64, err error) {
+// phases 请求各阶段耗时 下载阶段在读取 body 时由调用方记录
+func HTTPRequest(chanID uint64, request *model.RequestForm) (resp *http.Response, requestTime uint64,
+	phases *model.Phases, sentBytes int64, err error) {
 	method := request.Method
 	url := request.URL
 	body := request.GetBody()
@@ -50,6 +47,12 @@ func HTTPRequest(chanID uint64, request *model.RequestForm) (resp *http.Response
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
+	trace := &phaseTrace{}
+	req = req.WithContext(trace.withTrace(req.Context()))
+	defer func() {
+		phases = trace.result()
+		sentBytes = trace.sentBytes(req)
+	}()
 	var client *http.Client
 	if request.Keepalive {
 		client = httplongclinet.NewClient(chanID, request)
@@ -57,7 +60,7 @@ func HTTPRequest(chanID uint64, request *model.RequestForm) (resp *http.Response
 		resp, err = client.Do(req)
 		requestTime = uint64(tools.DiffNano(startTime))
 		if err != nil {
-			logErr.Println("请求失败:", err)
+			tools.Errorln("请求失败:", err)
 
 			return
 		}
@@ -90,7 +93,7 @@ func HTTPRequest(chanID uint64, request *model.RequestForm) (resp *http.Response
 	resp, err = client.Do(req)
 	requestTime = uint64(tools.DiffNano(startTime))
 	if err != nil {
-		logErr.Println("请求失败:", err)
+		tools.Errorln("请求失败:", err)
 
 		return
 	}
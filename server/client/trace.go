@@ -0,0 +1,114 @@
+// Package client http 客户端
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"goapistress/model"
+	"goapistress/tools"
+)
+
+// phaseTrace 通过 httptrace 记录 HTTP 请求各阶段耗时和发送的头信息大小
+// 回调可能在连接协程中执行，需要加锁
+type phaseTrace struct {
+	mutex        sync.Mutex
+	phases       model.Phases
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	headerBytes  int64 // 发送的头信息字节 http2 为压缩前的大小
+	http2        bool  // 是否为 http2 有 :method 等伪头
+	wrote        bool  // 请求是否已发送完成
+}
+
+// withTrace 在 ctx 中加入请求阶段跟踪
+func (t *phaseTrace) withTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mutex.Lock()
+			t.dnsStart = time.Now()
+			t.mutex.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mutex.Lock()
+			t.phases.DNS = uint64(tools.DiffNano(t.dnsStart))
+			t.mutex.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			t.mutex.Lock()
+			t.connectStart = time.Now()
+			t.mutex.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mutex.Lock()
+			t.phases.Connect = uint64(tools.DiffNano(t.connectStart))
+			t.mutex.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mutex.Lock()
+			t.tlsStart = time.Now()
+			t.mutex.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mutex.Lock()
+			t.phases.TLS = uint64(tools.DiffNano(t.tlsStart))
+			t.mutex.Unlock()
+		},
+		WroteHeaderField: func(key string, value []string) {
+			t.mutex.Lock()
+			// key: value\r\n
+			for _, v := range value {
+				t.headerBytes = t.headerBytes + int64(len(key)+len(v)+4)
+			}
+			if strings.HasPrefix(key, ":") {
+				t.http2 = true
+			}
+			t.mutex.Unlock()
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			t.mutex.Lock()
+			t.wroteRequest = time.Now()
+			t.wrote = info.Err == nil
+			t.mutex.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mutex.Lock()
+			t.phases.TTFB = uint64(tools.DiffNano(t.wroteRequest))
+			t.mutex.Unlock()
+		},
+	})
+}
+
+// result 各阶段耗时
+func (t *phaseTrace) result() *model.Phases {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	phases := t.phases
+	return &phases
+}
+
+// sentBytes 发送的字节 请求行、头和 body，请求没有发送完成时为0
+func (t *phaseTrace) sentBytes(req *http.Request) int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.wrote {
+		return 0
+	}
+	// 头信息结束的空行
+	size := t.headerBytes + 2
+	if !t.http2 {
+		// METHOD URI HTTP/1.1\r\n
+		size = size + int64(len(req.Method)+len(req.URL.RequestURI())+len(" HTTP/1.1\r\n")+1)
+	}
+	if req.ContentLength > 0 {
+		size = size + req.ContentLength
+	}
+	return size
+}
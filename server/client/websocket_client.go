@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"golang.org/x/net/websocket"
+
+	"goapistress/tools"
 )
 
 const (
@@ -76,14 +78,14 @@ func (w *WebSocket) GetConn() (err error) {
 	for i = 0; i < connRetry; i++ {
 		conn, err = websocket.Dial(w.getLink(), "", w.getOrigin())
 		if err != nil {
-			fmt.Println("GetConn 建立连接失败 in...", i, err)
+			tools.Println("GetConn 建立连接失败 in...", i, err)
 			continue
 		}
 		w.conn = conn
 		return
 	}
 	if err != nil {
-		fmt.Println("GetConn 建立连接失败", i, err)
+		tools.Println("GetConn 建立连接失败", i, err)
 	}
 	return
 }
@@ -96,7 +98,7 @@ func (w *WebSocket) Write(body []byte) (err error) {
 	}
 	_, err = w.conn.Write(body)
 	if err != nil {
-		fmt.Println("发送数据失败:", err)
+		tools.Println("发送数据失败:", err)
 		return
 	}
 	return
@@ -111,7 +113,7 @@ func (w *WebSocket) Read() (msg []byte, err error) {
 	msg = make([]byte, 512)
 	n, err := w.conn.Read(msg)
 	if err != nil {
-		fmt.Println("接收数据失败:", err)
+		tools.Println("接收数据失败:", err)
 		return nil, err
 	}
 	return msg[:n], nil
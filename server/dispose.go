@@ -12,6 +12,7 @@ import (
 	"goapistress/server/golink"
 	"goapistress/server/statistics"
 	"goapistress/server/verify"
+	"goapistress/tools"
 )
 
 const (
@@ -29,75 +30,204 @@ func init() {
 	model.RegisterVerifyWebSocket("json", verify.WebSocketJSON)
 }
 
-// Dispose 处理函数
-func Dispose(ctx context.Context, concurrency, reqNumbersPerProd uint64, request *model.RequestForm) {
+// Observer 请求结果观察者 压测过程中的每个请求结果都会交给观察者，用于实时指标、结果日志等
+type Observer interface {
+	// Start 压测开始
+	Start(task *model.TaskForm, request *model.RequestForm, status *model.TaskStatus)
+	// Observe 处理一个请求结果 在接收结果的协程中依次调用，应尽快返回(阻塞会拖慢接收结果)，预热阶段的请求 WarmUp 为 true
+	Observe(data *model.RequestResults)
+	// Stop 压测结束 所有请求结果都已处理
+	Stop()
+}
+
+var (
+	// observers 请求结果观察者
+	observers []Observer
+)
+
+// RegisterObserver 注册请求结果观察者 需要在压测开始前注册
+func RegisterObserver(observer Observer) {
+	observers = append(observers, observer)
+}
+
+// observe 标记预热阶段的请求结果，依次交给观察者以后转发给统计，ch 关闭以后关闭转发的 channel
+func observe(ch <-chan *model.RequestResults, warm *statistics.WarmUp) <-chan *model.RequestResults {
+	forward := make(chan *model.RequestResults, cap(ch))
+	go func() {
+		defer close(forward)
+		for data := range ch {
+			warm.Mark(data)
+			for _, observer := range observers {
+				observer.Observe(data)
+			}
+			forward <- data
+		}
+	}()
+	return forward
+}
+
+// Dispose 处理函数 返回压测结果
+// 请求数和压测时长同时设置时，先到先结束
+func Dispose(ctx context.Context, task *model.TaskForm, request *model.RequestForm) (result *statistics.Result) {
+	// 任务超时(-taskTimeout)、中断信号 与压测时长区分结束原因
+	parent := ctx
+	if task.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.Duration)
+		defer cancel()
+	}
+	// 满足提前结束条件时结束压测
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	// 设置接收数据缓存
 	ch := make(chan *model.RequestResults, 1000)
 	var (
 		wg          sync.WaitGroup // 发送数据完成
 		wgReceiving sync.WaitGroup // 数据处理完成
+		status      = &model.TaskStatus{}
 	)
+	status.SetCancel(cancel)
+	for _, observer := range observers {
+		observer.Start(task, request, status)
+	}
+	warm := statistics.NewWarmUp(task, time.Now())
+	results := observe(ch, warm)
 	wgReceiving.Add(1)
-	go statistics.ReceivingResults(concurrency, ch, &wgReceiving)
+	go func() {
+		defer wgReceiving.Done()
+		result = statistics.ReceivingResults(task, status, warm, results)
+	}()
 
-	for chanID := uint64(0); chanID < concurrency; chanID++ {
-		wg.Add(1)
-		switch request.MP {
-		case model.MPTypeHTTP:
-			go golink.HTTP(ctx, chanID, ch, reqNumbersPerProd, &wg, request)
-		case model.MPTypeWebSocket:
-			switch connectionMode {
-			case 1:
-				// 连接以后再启动协程
-				ws := client.NewWebSocket(request.URL)
-				err := ws.GetConn()
-				if err != nil {
-					fmt.Println("连接失败:", chanID, err)
-					continue
-				}
-				go golink.WebSocket(ctx, chanID, ch, reqNumbersPerProd, &wg, request, ws)
-			case 2:
-				// 并发建立长链接
-				go func(i uint64) {
-					// 连接以后再启动协程
-					ws := client.NewWebSocket(request.URL)
-					err := ws.GetConn()
-					if err != nil {
-						fmt.Println("连接失败:", i, err)
-						return
-					}
-					golink.WebSocket(ctx, i, ch, reqNumbersPerProd, &wg, request, ws)
-				}(chanID)
-				// 注意:时间间隔太短会出现连接失败的报错 默认连接时长:20毫秒(公网连接)
-				time.Sleep(5 * time.Millisecond)
-			default:
-				data := fmt.Sprintf("不支持的类型:%d", connectionMode)
-				panic(data)
-			}
-		case model.MPTypeGRPC:
-			// 连接以后再启动协程
-			ws := client.NewGrpcSocket(request.URL)
-			err := ws.Link()
-			if err != nil {
-				fmt.Println("连接失败:", chanID, err)
-				continue
-			}
-			go golink.Grpc(ctx, chanID, ch, reqNumbersPerProd, &wg, request, ws)
-		case model.MPTypeRadius:
-			// Radius use udp, does not a connection
-			go golink.Radius(ctx, chanID, ch, reqNumbersPerProd, &wg, request)
+	// 请求调度 开放模型所有协程共用一个按速率发放请求的调度
+	newScheduler := func(pacing time.Duration) golink.Scheduler {
+		if task.Pacing > 0 {
+			pacing = task.Pacing
+		}
+		return golink.NewCountScheduler(task.Number, pacing, task.ThinkTime)
+	}
+	var rateScheduler *golink.RateScheduler
+	if task.IsOpen() {
+		rateAt := func(elapsed time.Duration) float64 {
+			rate, _ := task.Target(elapsed)
+			return rate
+		}
+		rateScheduler = golink.NewRateScheduler(rateAt, task.Concurrency*task.Number, status)
+		newScheduler = func(time.Duration) golink.Scheduler {
+			return rateScheduler
+		}
+	}
 
-		default:
-			// 类型不支持
-			wg.Done()
+	if task.IsStaged() && !task.IsOpen() {
+		// 闭合模型分阶段压测 按阶段增减协程
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runStages(ctx, task, status, func(workerCtx context.Context, chanID uint64, done func()) {
+				// 每个协程单独等待 退出时通知 runStages 更新并发数
+				var worker sync.WaitGroup
+				startWorker(workerCtx, chanID, ch, newScheduler, &worker, request)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					worker.Wait()
+					done()
+				}()
+			})
+		}()
+	} else {
+		status.SetWorkers(int(task.Workers()))
+		for chanID := uint64(0); chanID < task.Workers(); chanID++ {
+			startWorker(ctx, chanID, ch, newScheduler, &wg, request)
+		}
+		if task.IsStaged() {
+			// 开放模型分阶段压测 只更新阶段状态，速率由调度控制
+			go runStages(ctx, task, status, nil)
 		}
 	}
+	// 协程都启动以后再开始发放请求
+	if rateScheduler != nil {
+		go rateScheduler.Run(ctx)
+	}
 	// 等待所有的数据都发送完成
 	wg.Wait()
+	status.SetWorkers(0)
+	// 全屏面板关闭以后再输出 避免输出在备用屏幕上
+	switch reason := status.GetAbortReason(); {
+	case reason != "":
+		status.SetStopReason("提前结束 " + reason)
+	case parent.Err() == context.DeadlineExceeded:
+		status.SetStopReason("任务超时")
+	case ctx.Err() == context.DeadlineExceeded:
+		status.SetStopReason("达到压测时长")
+	case ctx.Err() == context.Canceled:
+		status.SetStopReason("压测被中断")
+	}
 	// 延时1毫秒 确保数据都处理完成了
 	time.Sleep(1 * time.Millisecond)
 	close(ch)
 	// 数据全部处理完成了
 	wgReceiving.Wait()
+	for _, observer := range observers {
+		observer.Stop()
+	}
 	return
 }
+
+// startWorker 按协议启动一个压测协程
+// newScheduler 生成协程的请求调度，pacing 为闭合模型未设置节奏时协议默认的节奏
+func startWorker(ctx context.Context, chanID uint64, ch chan<- *model.RequestResults,
+	newScheduler func(pacing time.Duration) golink.Scheduler, wg *sync.WaitGroup, request *model.RequestForm) {
+	wg.Add(1)
+	switch request.MP {
+	case model.MPTypeHTTP:
+		go golink.HTTP(ctx, chanID, ch, newScheduler(0), wg, request)
+	case model.MPTypeWebSocket:
+		switch connectionMode {
+		case 1:
+			// 连接以后再启动协程
+			ws := client.NewWebSocket(request.URL)
+			err := ws.GetConn()
+			if err != nil {
+				tools.Println("连接失败:", chanID, err)
+				wg.Done()
+				return
+			}
+			go golink.WebSocket(ctx, chanID, ch, newScheduler(golink.WebSocketPacing), wg, request, ws)
+		case 2:
+			// 并发建立长链接
+			go func(i uint64) {
+				// 连接以后再启动协程
+				ws := client.NewWebSocket(request.URL)
+				err := ws.GetConn()
+				if err != nil {
+					tools.Println("连接失败:", i, err)
+					wg.Done()
+					return
+				}
+				golink.WebSocket(ctx, i, ch, newScheduler(golink.WebSocketPacing), wg, request, ws)
+			}(chanID)
+			// 注意:时间间隔太短会出现连接失败的报错 默认连接时长:20毫秒(公网连接)
+			time.Sleep(5 * time.Millisecond)
+		default:
+			data := fmt.Sprintf("不支持的类型:%d", connectionMode)
+			panic(data)
+		}
+	case model.MPTypeGRPC:
+		// 连接以后再启动协程
+		ws := client.NewGrpcSocket(request.URL)
+		err := ws.Link()
+		if err != nil {
+			tools.Println("连接失败:", chanID, err)
+			wg.Done()
+			return
+		}
+		go golink.Grpc(ctx, chanID, ch, newScheduler(0), wg, request, ws)
+	case model.MPTypeRadius:
+		// Radius use udp, does not a connection
+		go golink.Radius(ctx, chanID, ch, newScheduler(0), wg, request)
+
+	default:
+		// 类型不支持
+		wg.Done()
+	}
+}
@@ -0,0 +1,53 @@
+// Package golink 连接
+package golink
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc/status"
+
+	"goapistress/model"
+)
+
+// classifyError 请求失败的错误分类 返回固定的错误码，无法分类时为 model.RequestErr
+func classifyError(err error) int {
+	if err == nil {
+		return model.HTTPOk
+	}
+	if s, ok := status.FromError(err); ok {
+		return model.ErrGRPCBase + int(s.Code())
+	}
+	var (
+		netErr       net.Error
+		dnsErr       *net.DNSError
+		recordErr    tls.RecordHeaderError
+		authorityErr x509.UnknownAuthorityError
+		certInvalid  x509.CertificateInvalidError
+		hostnameErr  x509.HostnameError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return model.ErrCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return model.ErrTimeout
+	case errors.As(err, &dnsErr):
+		return model.ErrDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return model.ErrConnRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return model.ErrConnReset
+	case errors.As(err, &recordErr), errors.As(err, &authorityErr), errors.As(err, &certInvalid),
+		errors.As(err, &hostnameErr), strings.Contains(err.Error(), "tls: "):
+		return model.ErrTLS
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return model.ErrEOF
+	}
+	return model.RequestErr
+}
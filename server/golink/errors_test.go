@@ -0,0 +1,63 @@
+// Package golink 连接
+package golink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"goapistress/model"
+)
+
+// timeoutErr 超时错误
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+// TestClassifyError 测试错误分类
+func TestClassifyError(t *testing.T) {
+	// 监听后立即关闭，得到一个拒绝连接的端口
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+	_, refusedErr := net.Dial("tcp", addr)
+
+	tt := map[string]struct {
+		err  error
+		code int
+	}{
+		"nil":      {err: nil, code: model.HTTPOk},
+		"timeout":  {err: &url.Error{Op: "Get", URL: "http://a", Err: timeoutErr{}}, code: model.ErrTimeout},
+		"deadline": {err: fmt.Errorf("call: %w", context.DeadlineExceeded), code: model.ErrTimeout},
+		"canceled": {err: &url.Error{Op: "Get", URL: "http://a", Err: context.Canceled}, code: model.ErrCanceled},
+		"refused":  {err: refusedErr, code: model.ErrConnRefused},
+		"dns":      {err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "a"}}, code: model.ErrDNS},
+		"reset":    {err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, code: model.ErrConnReset},
+		"tls":      {err: errors.New("remote error: tls: handshake failure"), code: model.ErrTLS},
+		"eof":      {err: &url.Error{Op: "Get", URL: "http://a", Err: io.EOF}, code: model.ErrEOF},
+		"grpc":     {err: status.Error(codes.Unavailable, "down"), code: model.ErrGRPCBase + int(codes.Unavailable)},
+		"unknown":  {err: errors.New("unknown"), code: model.RequestErr},
+	}
+	for name, value := range tt {
+		if code := classifyError(value.err); code != value.code {
+			t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, value.code, code)
+		}
+	}
+	if name := model.ErrName(model.ErrGRPCBase + int(codes.Unavailable)); name != "grpc_unavailable" {
+		t.Errorf("数据不一致 预期:%v 实际:%v", "grpc_unavailable", name)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/golang/protobuf/proto"
+
 	pb "goapistress/proto"
 	"goapistress/tools"
 
@@ -14,7 +16,7 @@ import (
 )
 
 // Grpc grpc 接口请求
-func Grpc(ctx context.Context, chanID uint64, ch chan<- *model.RequestResults, totalNumber uint64, wg *sync.WaitGroup,
+func Grpc(ctx context.Context, chanID uint64, ch chan<- *model.RequestResults, scheduler Scheduler, wg *sync.WaitGroup,
 	request *model.RequestForm, ws *client.GrpcSocket) {
 	defer func() {
 		wg.Done()
@@ -22,19 +24,26 @@ func Grpc(ctx context.Context, chanID uint64, ch chan<- *model.RequestResults, t
 	defer func() {
 		_ = ws.Close()
 	}()
-	for i := uint64(0); i < totalNumber; i++ {
-		grpcRequest(chanID, ch, i, request, ws)
+	for i := uint64(0); ; i++ {
+		intended, ok := scheduler.Next(ctx, i)
+		if !ok {
+			break
+		}
+		grpcRequest(chanID, ch, i, intended, request, ws)
 	}
 	return
 }
 
 // grpcRequest 请求
-func grpcRequest(chanID uint64, ch chan<- *model.RequestResults, i uint64, request *model.RequestForm,
+func grpcRequest(chanID uint64, ch chan<- *model.RequestResults, i uint64, intended time.Time,
+	request *model.RequestForm,
 	ws *client.GrpcSocket) {
 	var (
 		startTime = time.Now()
 		isSucceed = false
 		errCode   = model.HTTPOk
+		errMsg    string
+		sentBytes int64
 	)
 	// 需要发送的数据
 	conn := ws.GetConn()
@@ -49,10 +58,13 @@ func grpcRequest(chanID uint64, ch chan<- *model.RequestResults, i uint64, reque
 				UserName: request.Body,
 			}
 		)
+		// 发送的消息 protobuf 编码后的大小
+		sentBytes = int64(proto.Size(req))
 		rsp, err := c.HelloWorld(ctx, req)
 		// fmt.Printf("rsp:%+v", rsp)
 		if err != nil {
-			errCode = model.RequestErr
+			errCode = classifyError(err)
+			errMsg = err.Error()
 		} else {
 			// 200 为成功
 			if rsp.Code != 200 {
@@ -64,9 +76,14 @@ func grpcRequest(chanID uint64, ch chan<- *model.RequestResults, i uint64, reque
 	}
 	requestTime := uint64(tools.DiffNano(startTime))
 	requestResults := &model.RequestResults{
+		StartTime: startTime,
 		Time:      requestTime,
+		Delay:     getDelay(intended, startTime),
 		IsSucceed: isSucceed,
 		ErrCode:   errCode,
+		ErrMsg:    errMsg,
+		SentBytes: sentBytes,
+		Endpoint:  request.GetName(),
 	}
 	requestResults.SetID(chanID, i)
 	ch <- requestResults
@@ -4,51 +4,58 @@ package golink
 import (
 	"compress/gzip"
 	"context"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"sync"
+	"time"
 
 	"goapistress/model"
 	"goapistress/server/client"
+	"goapistress/tools"
 )
 
 // HTTP 请求
-func HTTP(ctx context.Context, chanID uint64, ch chan<- *model.RequestResults, totalNumber uint64, wg *sync.WaitGroup, request *model.RequestForm) {
+func HTTP(ctx context.Context, chanID uint64, ch chan<- *model.RequestResults, scheduler Scheduler, wg *sync.WaitGroup, request *model.RequestForm) {
 	defer func() {
 		wg.Done()
 	}()
 	// fmt.Printf("启动协程 编号:%05d \n", chanID)
-	for i := uint64(0); i < totalNumber; i++ {
-		if ctx.Err() != nil {
-			fmt.Printf("ctx.Err err: %v \n", ctx.Err())
+	for i := uint64(0); ; i++ {
+		intended, ok := scheduler.Next(ctx, i)
+		if !ok {
 			break
 		}
-
+		delay := getDelay(intended, time.Now())
 		listRF := getRequestList(request)
-		isSucceed, errCode, requestTime, contentLength := sendList(chanID, listRF)
-		requestResults := &model.RequestResults{
-			Time:          requestTime,
-			IsSucceed:     isSucceed,
-			ErrCode:       errCode,
-			ReceivedBytes: contentLength,
-		}
+		requestResults := sendList(chanID, listRF)
+		requestResults.Delay = delay
 		requestResults.SetID(chanID, i)
 		ch <- requestResults
 	}
 }
 
-// sendList 多个接口分步压测
-func sendList(chanID uint64, listRF []*model.RequestForm) (isSucceed bool, errCode int, requestTime uint64, contentLength int64) {
-	errCode = model.HTTPOk
+// sendList 多个接口分步压测 耗时为各步之和，多步时每一步的结果记录在 Steps 中
+func sendList(chanID uint64, listRF []*model.RequestForm) (requestResults *model.RequestResults) {
+	requestResults = &model.RequestResults{
+		StartTime: time.Now(),
+		ErrCode:   model.HTTPOk,
+	}
 	for _, rF := range listRF {
-		succeed, code, u, length := send(chanID, rF)
-		isSucceed = succeed
-		errCode = code
-		requestTime = requestTime + u
-		contentLength = contentLength + length
-		if !succeed {
+		step := send(chanID, rF)
+		requestResults.IsSucceed = step.IsSucceed
+		requestResults.ErrCode = step.ErrCode
+		requestResults.ErrMsg = step.ErrMsg
+		requestResults.Time = requestResults.Time + step.Time
+		requestResults.ReceivedBytes = requestResults.ReceivedBytes + step.ReceivedBytes
+		requestResults.SentBytes = requestResults.SentBytes + step.SentBytes
+		if len(listRF) == 1 {
+			requestResults.Endpoint = step.Endpoint
+			requestResults.Phases = step.Phases
+		} else {
+			requestResults.Steps = append(requestResults.Steps, step)
+		}
+		if !step.IsSucceed {
 			break
 		}
 	}
@@ -56,7 +63,7 @@ func sendList(chanID uint64, listRF []*model.RequestForm) (isSucceed bool, errCo
 }
 
 // send 发送一次请求
-func send(chanID uint64, rF *model.RequestForm) (bool, int, uint64, int64) {
+func send(chanID uint64, rF *model.RequestForm) *model.RequestResults {
 	var (
 		// startTime = time.Now()
 		isSucceed     = false
@@ -65,23 +72,40 @@ func send(chanID uint64, rF *model.RequestForm) (bool, int, uint64, int64) {
 		err           error
 		resp          *http.Response
 		requestTime   uint64
+		phases        *model.Phases
+		sentBytes     int64
+		errMsg        string
 	)
 	newRequest := getRequest(rF)
-
-	resp, requestTime, err = client.HTTPRequest(chanID, newRequest)
+	startTime := time.Now()
+	resp, requestTime, phases, sentBytes, err = client.HTTPRequest(chanID, newRequest)
 
 	if err != nil {
-		errCode = model.RequestErr // 请求错误
+		errCode = classifyError(err) // 请求错误
+		errMsg = err.Error()
 	} else {
 		// 此处原方式获取的数据长度可能是 -1，换成如下方式获取可获取到正确的长度
+		downloadStart := time.Now()
 		contentLength, err = getBodyLength(resp)
+		phases.Download = uint64(tools.DiffNano(downloadStart))
 		if err != nil {
 			contentLength = resp.ContentLength
 		}
 		// 验证请求是否成功
 		errCode, isSucceed = newRequest.GetVerifyHTTP()(newRequest, resp)
 	}
-	return isSucceed, errCode, requestTime, contentLength
+	return &model.RequestResults{
+		ChanID:        chanID,
+		StartTime:     startTime,
+		Time:          requestTime,
+		IsSucceed:     isSucceed,
+		ErrCode:       errCode,
+		ErrMsg:        errMsg,
+		ReceivedBytes: contentLength,
+		SentBytes:     sentBytes,
+		Endpoint:      newRequest.GetName(),
+		Phases:        phases,
+	}
 }
 
 // getBodyLength 获取响应数据长度
@@ -30,6 +30,7 @@ func init() {
 
 	// 压测第一步
 	clients = append(clients, &model.RequestForm{
+		Name:   "step1",                                      // 接口名称 分接口统计
 		URL:    "https://page.aliyun.com/delivery/plan/list", // 请求url
 		MP:     "http",                                       // 请求方式 示例参数:http/webSocket/tcp
 		Method: "POST",                                       // 请求方法 示例参数:GET/POST/PUT
@@ -45,6 +46,7 @@ func init() {
 
 	// 压测第二步
 	clients = append(clients, &model.RequestForm{
+		Name:   "step2",                                      // 接口名称 分接口统计
 		URL:    "https://page.aliyun.com/delivery/plan/list", // 请求url
 		MP:     "http",                                       // 请求方式 示例参数:http/webSocket/tcp
 		Method: "POST",                                       // 请求方法 示例参数:GET/POST/PUT
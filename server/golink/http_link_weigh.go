@@ -39,6 +39,7 @@ func init() {
 	// 需要压测的接口参数
 	clients := make([]Req, 0)
 	clients = append(clients, Req{req: &model.RequestForm{
+		Name:   "plan_list",                                  // 接口名称 分接口统计
 		URL:    "https://page.aliyun.com/delivery/plan/list", // 请求url
 		MP:     "http",                                       // 请求方式 示例参数:http/webSocket/tcp
 		Method: "POST",                                       // 请求方法 示例参数:GET/POST/PUT
@@ -53,6 +54,7 @@ func init() {
 	}, weights: 2})
 
 	clients = append(clients, Req{req: &model.RequestForm{
+		Name:   "plan_list_low",                              // 接口名称 分接口统计
 		URL:    "https://page.aliyun.com/delivery/plan/list", // 请求url
 		MP:     "http",                                       // 请求方式 示例参数:http/webSocket/tcp
 		Method: "POST",                                       // 请求方法 示例参数:GET/POST/PUT
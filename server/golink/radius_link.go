@@ -14,23 +14,30 @@ import (
 )
 
 // Grpc grpc 接口请求
-func Radius(ctx context.Context, chanID uint64, ch chan<- *model.RequestResults, totalNumber uint64, wg *sync.WaitGroup,
+func Radius(ctx context.Context, chanID uint64, ch chan<- *model.RequestResults, scheduler Scheduler, wg *sync.WaitGroup,
 	request *model.RequestForm) {
 	defer func() {
 		wg.Done()
 	}()
-	for i := uint64(0); i < totalNumber; i++ {
-		authRequest(chanID, ch, i, request)
+	for i := uint64(0); ; i++ {
+		intended, ok := scheduler.Next(ctx, i)
+		if !ok {
+			break
+		}
+		authRequest(chanID, ch, i, intended, request)
 	}
 	return
 }
 
 // grpcRequest 请求
-func authRequest(chanID uint64, ch chan<- *model.RequestResults, i uint64, request *model.RequestForm) {
+func authRequest(chanID uint64, ch chan<- *model.RequestResults, i uint64, intended time.Time,
+	request *model.RequestForm) {
 	var (
 		startTime = time.Now()
 		isSucceed = false
 		errCode   = int(radius.CodeAccessAccept)
+		errMsg    string
+		sentBytes int64
 	)
 	// 需要发送的数据
 	// fmt.Printf("rsp:%+v", rsp)
@@ -47,9 +54,11 @@ func authRequest(chanID uint64, ch chan<- *model.RequestResults, i uint64, reque
 	rfc2865.NASPortType_Set(packet, rfc2865.NASPortType_Value_Ethernet)
 	rfc2865.ServiceType_Set(packet, rfc2865.ServiceType_Value_FramedUser)
 	rfc2865.NASIdentifier_Set(packet, []byte(`benchmark`))
+	sentBytes = packetSize(packet)
 	rsp, err := radius.Exchange(context.Background(), packet, host)
 	if err != nil {
-		errCode = model.RequestErr
+		errCode = classifyError(err)
+		errMsg = err.Error()
 	} else {
 		if rsp.Code != radius.CodeAccessAccept {
 			errCode = int(rsp.Code)
@@ -59,10 +68,29 @@ func authRequest(chanID uint64, ch chan<- *model.RequestResults, i uint64, reque
 	}
 	requestTime := uint64(tools.DiffNano(startTime))
 	requestResults := &model.RequestResults{
+		StartTime: startTime,
 		Time:      requestTime,
+		Delay:     getDelay(intended, startTime),
 		IsSucceed: isSucceed,
 		ErrCode:   errCode,
+		ErrMsg:    errMsg,
+		SentBytes: sentBytes,
+		Endpoint:  request.GetName(),
 	}
 	requestResults.SetID(chanID, i)
 	ch <- requestResults
 }
+
+// packetSize 发送的数据包编码后的大小 按属性计算，不再单独编码一次
+// 头部20字节(Code、Identifier、Length、Authenticator)，每个属性 Type、Length 各1字节加属性值
+func packetSize(packet *radius.Packet) (size int64) {
+	size = 20
+	for _, attr := range packet.Attributes {
+		if attr.Type < 0 || attr.Type > 255 || len(attr.Attribute) > 253 {
+			// 编码时忽略不合法的属性
+			continue
+		}
+		size = size + 2 + int64(len(attr.Attribute))
+	}
+	return
+}
@@ -0,0 +1,27 @@
+// Package golink 连接
+package golink
+
+import (
+	"testing"
+
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// Test_packetSize 测试按属性计算的大小与编码后的大小一致
+func Test_packetSize(t *testing.T) {
+	packet := radius.New(radius.CodeAccessRequest, []byte(`cisco`))
+	rfc2865.UserName_SetString(packet, "tim@example.com")
+	// 密码按16字节分组加密 用整组长度的密码
+	rfc2865.UserPassword_SetString(packet, "1234567812345678")
+	rfc2865.NASPortType_Set(packet, rfc2865.NASPortType_Value_Ethernet)
+	rfc2865.ServiceType_Set(packet, rfc2865.ServiceType_Value_FramedUser)
+	rfc2865.NASIdentifier_Set(packet, []byte(`benchmark`))
+	encoded, err := packet.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := packetSize(packet); size != int64(len(encoded)) {
+		t.Errorf("数据不一致 预期:%v 实际:%v", len(encoded), size)
+	}
+}
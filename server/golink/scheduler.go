@@ -0,0 +1,173 @@
+// Package golink 连接
+package golink
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"goapistress/model"
+)
+
+// Scheduler 请求调度 决定协程何时发起下一次请求
+type Scheduler interface {
+	// Next 等待发起第 i 次请求(协程内序号)，返回计划发起时间，ok 为 false 时协程结束
+	Next(ctx context.Context, i uint64) (intended time.Time, ok bool)
+}
+
+// isEnd 是否结束发送
+// 请求数达到 totalNumber(0 为不限制) 或 ctx 结束(压测时长到达、任务超时)时结束
+func isEnd(ctx context.Context, i, totalNumber uint64) bool {
+	if totalNumber > 0 && i >= totalNumber {
+		return true
+	}
+	return ctx.Err() != nil
+}
+
+// getDelay 实际发起时间比计划发起时间晚的时长 纳秒
+func getDelay(intended, startTime time.Time) uint64 {
+	if delay := startTime.Sub(intended); delay > 0 {
+		return uint64(delay)
+	}
+	return 0
+}
+
+// sleep 等待 d 时长，ctx 结束时提前返回 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// countScheduler 闭合模型 每个协程收到响应后再发起下一次请求
+type countScheduler struct {
+	totalNumber uint64           // 请求数 0:不限制
+	pacing      time.Duration    // 两次请求发起的间隔 0:不间隔
+	think       *model.ThinkTime // 思考时间 上一次请求完成以后等待的时长
+	rand        *rand.Rand       // 思考时间随机数
+	lastTime    time.Time        // 上一次请求发起时间
+}
+
+// NewCountScheduler 闭合模型调度，每个协程需要单独创建
+// totalNumber 请求数(单个协程) 0:不限制
+// pacing 两次请求发起的间隔，响应超过 pacing 时立即发起下一次
+// think 思考时间 为空时不等待
+func NewCountScheduler(totalNumber uint64, pacing time.Duration, think *model.ThinkTime) Scheduler {
+	return &countScheduler{
+		totalNumber: totalNumber,
+		pacing:      pacing,
+		think:       think,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Next 等待发起第 i 次请求 闭合模型没有计划发起时间，以实际发起时间为准
+// 先等待思考时间，再等待到节奏规定的发起时间
+func (c *countScheduler) Next(ctx context.Context, i uint64) (time.Time, bool) {
+	if isEnd(ctx, i, c.totalNumber) {
+		return time.Time{}, false
+	}
+	if i > 0 && c.think != nil {
+		if !sleep(ctx, c.think.Duration(c.rand)) {
+			return time.Time{}, false
+		}
+	}
+	if i > 0 && c.pacing > 0 {
+		if !sleep(ctx, c.pacing-time.Since(c.lastTime)) {
+			return time.Time{}, false
+		}
+	}
+	c.lastTime = time.Now()
+	return c.lastTime, true
+}
+
+// RateScheduler 开放模型调度 按到达速率发放请求，与目标的响应快慢无关
+// 所有协程共用，没有空闲协程(达到最大并发)时该次请求被丢弃并计数，避免协调遗漏
+type RateScheduler struct {
+	rateAt      func(elapsed time.Duration) float64 // 压测进行 elapsed 时长时的每秒请求数
+	totalNumber uint64                              // 请求总数 0:不限制
+	tickets     chan time.Time                      // 请求计划发起时间
+	status      *model.TaskStatus                   // 运行状态 记录丢弃数
+}
+
+// NewRateScheduler 开放模型调度
+// rateAt 压测进行 elapsed 时长时的每秒请求数，分阶段压测时随时间变化
+// totalNumber 请求总数 0:不限制
+func NewRateScheduler(rateAt func(elapsed time.Duration) float64, totalNumber uint64,
+	status *model.TaskStatus) *RateScheduler {
+	return &RateScheduler{
+		rateAt:      rateAt,
+		totalNumber: totalNumber,
+		tickets:     make(chan time.Time),
+		status:      status,
+	}
+}
+
+// Run 按速率发放请求，直到达到请求总数或 ctx 结束
+// 到达数为速率对时间的积分，速率变化时最长 maxStep 重新计算一次
+func (r *RateScheduler) Run(ctx context.Context) {
+	defer close(r.tickets)
+	var (
+		startTime = time.Now()
+		scheduled uint64                   // 已到达(发放+丢弃)的请求数
+		arrivals  float64                  // 截至 lastTime 应到达的请求数
+		lastTime  time.Duration            // 上一次计算的时间
+		lastRate  = r.rateAt(0)            // 上一次计算的速率
+		maxStep   = 100 * time.Millisecond // 最长计算间隔
+	)
+	for {
+		now := time.Since(startTime)
+		rate := r.rateAt(now)
+		add := (lastRate + rate) / 2 * (now - lastTime).Seconds()
+		for float64(scheduled)+1 <= arrivals+add {
+			if r.totalNumber > 0 && scheduled >= r.totalNumber {
+				return
+			}
+			// 在两次计算之间按比例还原计划发起时间
+			intended := startTime.Add(now)
+			if add > 0 {
+				ratio := (float64(scheduled) + 1 - arrivals) / add
+				intended = startTime.Add(lastTime + time.Duration(ratio*float64(now-lastTime)))
+			}
+			select {
+			case r.tickets <- intended:
+			default:
+				// 没有空闲协程，丢弃
+				r.status.AddDropped(1)
+			}
+			scheduled++
+		}
+		arrivals, lastTime, lastRate = arrivals+add, now, rate
+		if r.totalNumber > 0 && scheduled >= r.totalNumber {
+			return
+		}
+		// 等待下一个请求到达
+		wait := maxStep
+		if rate > 0 {
+			if d := time.Duration((float64(scheduled) + 1 - arrivals) / rate * float64(time.Second)); d < wait {
+				wait = d
+			}
+		}
+		if !sleep(ctx, wait) {
+			return
+		}
+	}
+}
+
+// Next 等待发放请求 返回请求的计划发起时间
+func (r *RateScheduler) Next(ctx context.Context, _ uint64) (time.Time, bool) {
+	select {
+	case intended, ok := <-r.tickets:
+		return intended, ok
+	case <-ctx.Done():
+		return time.Time{}, false
+	}
+}
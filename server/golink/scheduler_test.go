@@ -0,0 +1,144 @@
+// Package golink 连接
+package golink
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"goapistress/model"
+)
+
+// runRateScheduler 按速率发放请求 workers 个协程等待请求
+// 返回发放的计划发起时间(相对开始时间 从小到大)、丢弃数和 Run 的运行时长
+func runRateScheduler(rate float64, total uint64, workers int, timeout time.Duration) (intended []time.Duration,
+	dropped uint64, elapsed time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	status := &model.TaskStatus{}
+	scheduler := NewRateScheduler(func(time.Duration) float64 { return rate }, total, status)
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+	startTime := time.Now()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				t, ok := scheduler.Next(ctx, 0)
+				if !ok {
+					return
+				}
+				mutex.Lock()
+				intended = append(intended, t.Sub(startTime))
+				mutex.Unlock()
+			}
+		}()
+	}
+	scheduler.Run(ctx)
+	elapsed = time.Since(startTime)
+	wg.Wait()
+	sort.Slice(intended, func(i, j int) bool {
+		return intended[i] < intended[j]
+	})
+	return intended, status.GetDropped(), elapsed
+}
+
+// TestRateScheduler 测试按速率发放、没有空闲协程时丢弃、达到请求总数和 ctx 结束时停止
+func TestRateScheduler(t *testing.T) {
+	tt := map[string]struct {
+		rate       float64
+		total      uint64
+		workers    int
+		timeout    time.Duration
+		minSent    int           // 发放数下限
+		maxSent    int           // 发放数上限
+		minDropped uint64        // 丢弃数下限
+		maxDropped uint64        // 丢弃数上限
+		arrivals   int           // 发放+丢弃 为0时不判断
+		maxElapsed time.Duration // Run 最长运行时长
+	}{
+		// 每秒200个 0.5秒到达约100个 协程都空闲时不丢弃
+		"rate": {rate: 200, workers: 4, timeout: 500 * time.Millisecond, minSent: 90, maxSent: 101,
+			maxDropped: 2, maxElapsed: time.Second},
+		// 没有空闲协程(达到最大并发) 全部丢弃
+		"saturated": {rate: 200, workers: 0, timeout: 500 * time.Millisecond, minDropped: 90, maxDropped: 101,
+			maxElapsed: time.Second},
+		// 达到请求总数(-c * -n)以后停止 不等待 ctx 结束
+		"number": {rate: 1000, total: 10, workers: 2, timeout: 5 * time.Second, minSent: 8, maxSent: 10,
+			maxDropped: 2, arrivals: 10, maxElapsed: time.Second},
+		// 速率为0时 ctx 结束后停止
+		"cancel": {rate: 0, workers: 1, timeout: 100 * time.Millisecond, maxElapsed: time.Second},
+	}
+	for name, value := range tt {
+		intended, dropped, elapsed := runRateScheduler(value.rate, value.total, value.workers, value.timeout)
+		if len(intended) < value.minSent || len(intended) > value.maxSent {
+			t.Errorf("%s 发放数 数据不一致 预期:%v~%v 实际:%v", name, value.minSent, value.maxSent, len(intended))
+		}
+		if dropped < value.minDropped || dropped > value.maxDropped {
+			t.Errorf("%s 丢弃数 数据不一致 预期:%v~%v 实际:%v", name, value.minDropped, value.maxDropped, dropped)
+		}
+		if value.arrivals > 0 && len(intended)+int(dropped) != value.arrivals {
+			t.Errorf("%s 到达数 数据不一致 预期:%v 实际:%v", name, value.arrivals, len(intended)+int(dropped))
+		}
+		if elapsed > value.maxElapsed {
+			t.Errorf("%s 运行时长 数据不一致 预期:<%v 实际:%v", name, value.maxElapsed, elapsed)
+		}
+	}
+}
+
+// TestRateSchedulerIntended 测试计划发起时间按速率均匀分布 与协程何时取到请求无关
+func TestRateSchedulerIntended(t *testing.T) {
+	rate := 100.0
+	intended, _, _ := runRateScheduler(rate, 20, 4, 5*time.Second)
+	if len(intended) == 0 {
+		t.Fatalf("数据不一致 预期:%v 实际:%v", 20, 0)
+	}
+	for i, value := range intended {
+		// 第 i 个请求在 (i+1)/rate 秒到达 调度误差20ms以内
+		want := time.Duration(float64(i+1) / rate * float64(time.Second))
+		if diff := value - want; diff < -20*time.Millisecond || diff > 20*time.Millisecond {
+			t.Errorf("第%d个请求 计划发起时间 数据不一致 预期:%v 实际:%v", i+1, want, value)
+		}
+	}
+}
+
+// TestCountScheduler 测试闭合模型 达到请求数、ctx 结束时停止，节奏(-pacing)控制两次发起的间隔
+func TestCountScheduler(t *testing.T) {
+	tt := map[string]struct {
+		total    uint64
+		pacing   time.Duration
+		timeout  time.Duration
+		count    uint64        // 发起的请求数
+		minSpent time.Duration // 最短耗时
+	}{
+		"number": {total: 3, timeout: time.Second, count: 3},
+		"pacing": {total: 3, pacing: 20 * time.Millisecond, timeout: time.Second, count: 3,
+			minSpent: 40 * time.Millisecond},
+		"cancel": {pacing: 40 * time.Millisecond, timeout: 100 * time.Millisecond, count: 3},
+	}
+	for name, value := range tt {
+		ctx, cancel := context.WithTimeout(context.Background(), value.timeout)
+		scheduler := NewCountScheduler(value.total, value.pacing, nil)
+		startTime := time.Now()
+		var i uint64
+		for {
+			if _, ok := scheduler.Next(ctx, i); !ok {
+				break
+			}
+			i++
+		}
+		spent := time.Since(startTime)
+		cancel()
+		if i != value.count {
+			t.Errorf("%s 请求数 数据不一致 预期:%v 实际:%v", name, value.count, i)
+		}
+		if spent < value.minSpent {
+			t.Errorf("%s 耗时 数据不一致 预期:>=%v 实际:%v", name, value.minSpent, spent)
+		}
+	}
+}
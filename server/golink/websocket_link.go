@@ -13,8 +13,9 @@ import (
 )
 
 const (
-	firstTime    = 1 * time.Second // 连接以后首次请求数据的时间
-	intervalTime = 1 * time.Second // 发送数据的时间间隔
+	firstTime = 1 * time.Second // 连接以后首次请求数据的时间
+	// WebSocketPacing 闭合模型未设置节奏时发送数据的时间间隔
+	WebSocketPacing = 1 * time.Second
 )
 
 var (
@@ -27,7 +28,7 @@ func init() {
 }
 
 // WebSocket webSocket go link
-func WebSocket(ctx context.Context, chanID uint64, ch chan<- *model.RequestResults, totalNumber uint64,
+func WebSocket(ctx context.Context, chanID uint64, ch chan<- *model.RequestResults, scheduler Scheduler,
 	wg *sync.WaitGroup, request *model.RequestForm, ws *client.WebSocket) {
 	defer func() {
 		wg.Done()
@@ -36,63 +37,64 @@ func WebSocket(ctx context.Context, chanID uint64, ch chan<- *model.RequestResul
 		_ = ws.Close()
 	}()
 
-	var (
-		i uint64
-	)
-	// 暂停60秒
-	t := time.NewTimer(firstTime)
-	for {
-		select {
-		case <-t.C:
-			t.Reset(intervalTime)
-			// 请求
-			webSocketRequest(chanID, ch, i, request, ws)
-			// 结束条件
-			i = i + 1
-			if i >= totalNumber {
-				goto end
+	// 连接以后等待 firstTime 再开始请求
+	if sleep(ctx, firstTime) {
+		for i := uint64(0); ; i++ {
+			intended, ok := scheduler.Next(ctx, i)
+			if !ok {
+				break
 			}
+			webSocketRequest(chanID, ch, i, intended, request, ws)
 		}
 	}
-end:
-	t.Stop()
 
 	if keepAlive {
-		// 保持连接
-		chWaitFor := make(chan int, 0)
-		<-chWaitFor
+		// 保持连接，直到压测结束
+		<-ctx.Done()
 	}
 	return
 }
 
 // webSocketRequest 请求
-func webSocketRequest(chanID uint64, ch chan<- *model.RequestResults, i uint64, request *model.RequestForm,
+func webSocketRequest(chanID uint64, ch chan<- *model.RequestResults, i uint64, intended time.Time,
+	request *model.RequestForm,
 	ws *client.WebSocket) {
 	var (
 		startTime = time.Now()
 		isSucceed = false
 		errCode   = model.HTTPOk
+		errMsg    string
 		msg       []byte
 	)
 	// 需要发送的数据
 	seq := fmt.Sprintf("%d_%d", chanID, i)
-	err := ws.Write([]byte(`{"seq":"` + seq + `","cmd":"ping","data":{}}`))
+	data := []byte(`{"seq":"` + seq + `","cmd":"ping","data":{}}`)
+	sentBytes := int64(0)
+	err := ws.Write(data)
 	if err != nil {
-		errCode = model.RequestErr // 请求错误
+		errCode = classifyError(err) // 请求错误
+		errMsg = err.Error()
 	} else {
+		sentBytes = int64(len(data))
 		msg, err = ws.Read()
 		if err != nil {
 			errCode = model.ParseError
-			fmt.Println("读取数据 失败~")
+			errMsg = err.Error()
+			tools.Println("读取数据 失败~")
 		} else {
 			errCode, isSucceed = request.GetVerifyWebSocket()(request, seq, msg)
 		}
 	}
 	requestTime := uint64(tools.DiffNano(startTime))
 	requestResults := &model.RequestResults{
+		StartTime: startTime,
 		Time:      requestTime,
+		Delay:     getDelay(intended, startTime),
 		IsSucceed: isSucceed,
 		ErrCode:   errCode,
+		ErrMsg:    errMsg,
+		SentBytes: sentBytes,
+		Endpoint:  request.GetName(),
 	}
 	requestResults.SetID(chanID, i)
 	ch <- requestResults
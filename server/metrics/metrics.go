@@ -0,0 +1,236 @@
+// Package metrics 压测过程中的实时指标 以 Prometheus 文本格式对外提供
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"goapistress/model"
+)
+
+// latencyBuckets 耗时直方图区间上限 秒
+var latencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// requestKey 请求数的标签
+type requestKey struct {
+	protocol string
+	endpoint string
+	code     int
+	result   string
+}
+
+// endpointKey 接口的标签
+type endpointKey struct {
+	protocol string
+	endpoint string
+}
+
+// latency 耗时直方图
+type latency struct {
+	buckets []uint64 // 每个区间上限对应的累计次数
+	count   uint64   // 总次数
+	sum     float64  // 耗时之和 秒
+}
+
+// Collector 实时指标 实现 server.Observer，接收压测的请求结果
+type Collector struct {
+	mutex         sync.Mutex
+	protocol      string                   // 当前压测的协议
+	status        *model.TaskStatus        // 当前压测的运行状态
+	requests      map[requestKey]uint64    // 请求数
+	latencies     map[endpointKey]*latency // 耗时分布
+	receivedBytes map[endpointKey]int64    // 下载字节
+	sentBytes     map[endpointKey]int64    // 上传字节
+	dropped       uint64                   // 已结束压测的丢弃数
+}
+
+// NewCollector 实时指标
+func NewCollector() *Collector {
+	return &Collector{
+		requests:      make(map[requestKey]uint64),
+		latencies:     make(map[endpointKey]*latency),
+		receivedBytes: make(map[endpointKey]int64),
+		sentBytes:     make(map[endpointKey]int64),
+	}
+}
+
+// Listen 在 addr 上提供 /metrics 接口 示例: :9100
+func Listen(addr string) (collector *Collector, err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	collector = NewCollector()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", collector)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			fmt.Println("metrics 服务退出:", err)
+		}
+	}()
+	return collector, nil
+}
+
+// Start 压测开始 容量搜索时每一级压测都会调用，指标累计
+func (c *Collector) Start(task *model.TaskForm, request *model.RequestForm, status *model.TaskStatus) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.protocol = request.MP
+	c.status = status
+}
+
+// Observe 记录一个请求结果 分步压测时按每一步的接口记录，预热阶段的请求不记录
+func (c *Collector) Observe(data *model.RequestResults) {
+	if data.WarmUp {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(data.Steps) == 0 {
+		c.record(data)
+		return
+	}
+	for _, step := range data.Steps {
+		c.record(step)
+	}
+}
+
+// record 按接口记录
+func (c *Collector) record(data *model.RequestResults) {
+	result := "success"
+	if !data.IsSucceed {
+		result = "failure"
+	}
+	c.requests[requestKey{c.protocol, data.Endpoint, data.ErrCode, result}]++
+	key := endpointKey{c.protocol, data.Endpoint}
+	l, ok := c.latencies[key]
+	if !ok {
+		l = &latency{buckets: make([]uint64, len(latencyBuckets))}
+		c.latencies[key] = l
+	}
+	seconds := float64(data.Time) / 1e9
+	for i, upper := range latencyBuckets {
+		if seconds <= upper {
+			l.buckets[i]++
+		}
+	}
+	l.count++
+	l.sum = l.sum + seconds
+	c.receivedBytes[key] = c.receivedBytes[key] + data.ReceivedBytes
+	c.sentBytes[key] = c.sentBytes[key] + data.SentBytes
+}
+
+// Stop 压测结束
+func (c *Collector) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.dropped = c.dropped + c.status.GetDropped()
+	c.status = nil
+}
+
+// ServeHTTP 输出 Prometheus 文本格式的指标
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Export(w)
+}
+
+// Export 输出 Prometheus 文本格式的指标
+// 持有锁时只生成到缓冲中，释放锁以后再写入 w，抓取很慢时不阻塞 Observe
+func (c *Collector) Export(w io.Writer) {
+	var b bytes.Buffer
+	c.render(&b)
+	_, _ = w.Write(b.Bytes())
+}
+
+// render 生成 Prometheus 文本格式的指标
+func (c *Collector) render(w *bytes.Buffer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	fmt.Fprintln(w, "# HELP goapistress_requests_total 请求数")
+	fmt.Fprintln(w, "# TYPE goapistress_requests_total counter")
+	requestKeys := make([]requestKey, 0, len(c.requests))
+	for key := range c.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, b := requestKeys[i], requestKeys[j]
+		if a.endpoint != b.endpoint {
+			return a.endpoint < b.endpoint
+		}
+		if a.code != b.code {
+			return a.code < b.code
+		}
+		return a.result < b.result
+	})
+	for _, key := range requestKeys {
+		fmt.Fprintf(w, "goapistress_requests_total{%s,code=\"%d\",result=\"%s\"} %d\n",
+			labels(key.protocol, key.endpoint), key.code, key.result, c.requests[key])
+	}
+
+	endpointKeys := make([]endpointKey, 0, len(c.latencies))
+	for key := range c.latencies {
+		endpointKeys = append(endpointKeys, key)
+	}
+	sort.Slice(endpointKeys, func(i, j int) bool {
+		return endpointKeys[i].protocol+endpointKeys[i].endpoint < endpointKeys[j].protocol+endpointKeys[j].endpoint
+	})
+	fmt.Fprintln(w, "# HELP goapistress_request_duration_seconds 请求耗时")
+	fmt.Fprintln(w, "# TYPE goapistress_request_duration_seconds histogram")
+	for _, key := range endpointKeys {
+		l := c.latencies[key]
+		for i, upper := range latencyBuckets {
+			fmt.Fprintf(w, "goapistress_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels(key.protocol, key.endpoint), strconv.FormatFloat(upper, 'f', -1, 64), l.buckets[i])
+		}
+		fmt.Fprintf(w, "goapistress_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n",
+			labels(key.protocol, key.endpoint), l.count)
+		fmt.Fprintf(w, "goapistress_request_duration_seconds_sum{%s} %s\n", labels(key.protocol, key.endpoint),
+			strconv.FormatFloat(l.sum, 'f', -1, 64))
+		fmt.Fprintf(w, "goapistress_request_duration_seconds_count{%s} %d\n", labels(key.protocol, key.endpoint),
+			l.count)
+	}
+	fmt.Fprintln(w, "# HELP goapistress_received_bytes_total 下载字节")
+	fmt.Fprintln(w, "# TYPE goapistress_received_bytes_total counter")
+	for _, key := range endpointKeys {
+		fmt.Fprintf(w, "goapistress_received_bytes_total{%s} %d\n", labels(key.protocol, key.endpoint),
+			c.receivedBytes[key])
+	}
+	fmt.Fprintln(w, "# HELP goapistress_sent_bytes_total 上传字节")
+	fmt.Fprintln(w, "# TYPE goapistress_sent_bytes_total counter")
+	for _, key := range endpointKeys {
+		fmt.Fprintf(w, "goapistress_sent_bytes_total{%s} %d\n", labels(key.protocol, key.endpoint),
+			c.sentBytes[key])
+	}
+
+	var (
+		workers int
+		dropped = c.dropped
+	)
+	if c.status != nil {
+		workers = c.status.GetWorkers()
+		dropped = dropped + c.status.GetDropped()
+	}
+	fmt.Fprintln(w, "# HELP goapistress_active_workers 运行中的压测协程数")
+	fmt.Fprintln(w, "# TYPE goapistress_active_workers gauge")
+	fmt.Fprintf(w, "goapistress_active_workers %d\n", workers)
+	fmt.Fprintln(w, "# HELP goapistress_dropped_requests_total 开放模型没有空闲协程而丢弃的请求数")
+	fmt.Fprintln(w, "# TYPE goapistress_dropped_requests_total counter")
+	fmt.Fprintf(w, "goapistress_dropped_requests_total %d\n", dropped)
+}
+
+// labels 协议、接口标签
+func labels(protocol, endpoint string) string {
+	return fmt.Sprintf("protocol=\"%s\",endpoint=\"%s\"", escape(protocol), escape(endpoint))
+}
+
+// escape 标签值转义
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,62 @@
+// Package metrics 实时指标
+package metrics
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"goapistress/model"
+)
+
+// TestCollectorExport 测试 Prometheus 文本格式输出
+func TestCollectorExport(t *testing.T) {
+	collector := NewCollector()
+	collector.Start(&model.TaskForm{}, &model.RequestForm{MP: model.MPTypeHTTP}, &model.TaskStatus{})
+	collector.Observe(&model.RequestResults{Time: 2e6, IsSucceed: true, ErrCode: 200, Endpoint: "a", ReceivedBytes: 10})
+	collector.Observe(&model.RequestResults{Time: 3e9, IsSucceed: false, ErrCode: 500, Endpoint: "a"})
+	// 预热阶段的请求不计入
+	collector.Observe(&model.RequestResults{Time: 1e6, IsSucceed: true, ErrCode: 200, Endpoint: "a", WarmUp: true})
+	collector.Stop()
+	var b strings.Builder
+	collector.Export(&b)
+	for _, line := range []string{
+		`goapistress_requests_total{protocol="http",endpoint="a",code="200",result="success"} 1`,
+		`goapistress_requests_total{protocol="http",endpoint="a",code="500",result="failure"} 1`,
+		`goapistress_request_duration_seconds_bucket{protocol="http",endpoint="a",le="0.0025"} 1`,
+		`goapistress_request_duration_seconds_bucket{protocol="http",endpoint="a",le="5"} 2`,
+		`goapistress_request_duration_seconds_count{protocol="http",endpoint="a"} 2`,
+		`goapistress_received_bytes_total{protocol="http",endpoint="a"} 10`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("数据不一致 缺少:%s", line)
+		}
+	}
+}
+
+// TestCollectorSlowScrape 测试抓取方不读取时 Observe 不被阻塞
+func TestCollectorSlowScrape(t *testing.T) {
+	collector := NewCollector()
+	collector.Start(&model.TaskForm{}, &model.RequestForm{MP: model.MPTypeHTTP}, &model.TaskStatus{})
+	reader, writer := io.Pipe()
+	exported := make(chan struct{})
+	go func() {
+		// 没有读取方 写入一直阻塞
+		collector.Export(writer)
+		close(exported)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	observed := make(chan struct{})
+	go func() {
+		collector.Observe(&model.RequestResults{Time: 2e6, IsSucceed: true, ErrCode: 200, Endpoint: "a"})
+		close(observed)
+	}()
+	select {
+	case <-observed:
+	case <-time.After(time.Second):
+		t.Errorf("数据不一致 预期:%v 实际:%v", "Observe 返回", "Observe 被阻塞")
+	}
+	_ = reader.Close()
+	<-exported
+}
@@ -0,0 +1,105 @@
+// Package push 推送指标
+package push
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"goapistress/server/statistics"
+)
+
+// statsD StatsD 格式 计数为 c，其它为 g，标签为 DogStatsD 格式 |#key:value
+func (s *Sink) statsD(interval *statistics.Interval) (lines []string) {
+	tags := make([]string, len(s.tags))
+	for i, tag := range s.tags {
+		tags[i] = tag.Key + ":" + tag.Value
+	}
+	suffix := ""
+	if len(tags) > 0 {
+		suffix = "|#" + strings.Join(tags, ",")
+	}
+	metric := func(name, value, kind string) {
+		lines = append(lines, fmt.Sprintf("%s.%s:%s|%s%s", prefix, name, value, kind, suffix))
+	}
+	metric("requests.success", strconv.FormatUint(interval.SuccessNum, 10), "c")
+	metric("requests.failure", strconv.FormatUint(interval.FailureNum, 10), "c")
+	metric("requests.dropped", strconv.FormatUint(interval.Dropped, 10), "c")
+	metric("received_bytes", strconv.FormatInt(interval.ReceivedBytes, 10), "c")
+	metric("sent_bytes", strconv.FormatInt(interval.SentBytes, 10), "c")
+	metric("workers", strconv.Itoa(interval.Workers), "g")
+	metric("qps", formatFloat(interval.QPS), "g")
+	metric("latency.avg_ms", formatFloat(interval.AverageTime), "g")
+	metric("latency.max_ms", formatFloat(interval.MaxTime), "g")
+	for _, name := range percentileNames(interval) {
+		metric("latency."+name+"_ms", formatFloat(interval.Percentiles[name]), "g")
+	}
+	for _, code := range errCodes(interval) {
+		codeSuffix := "|#code:" + strconv.Itoa(code)
+		if suffix != "" {
+			codeSuffix = suffix + ",code:" + strconv.Itoa(code)
+		}
+		lines = append(lines, fmt.Sprintf("%s.requests.code:%d|c%s", prefix, interval.ErrCode[code], codeSuffix))
+	}
+	return
+}
+
+// influx InfluxDB 行协议 一行统计周期数据，每个错误码一行
+func (s *Sink) influx(interval *statistics.Interval) (lines []string) {
+	var tags strings.Builder
+	for _, tag := range s.tags {
+		tags.WriteString("," + escapeInflux(tag.Key) + "=" + escapeInflux(tag.Value))
+	}
+	timestamp := interval.Time.UnixNano()
+	fields := []string{
+		"workers=" + strconv.Itoa(interval.Workers) + "i",
+		"success=" + strconv.FormatUint(interval.SuccessNum, 10) + "i",
+		"failure=" + strconv.FormatUint(interval.FailureNum, 10) + "i",
+		"dropped=" + strconv.FormatUint(interval.Dropped, 10) + "i",
+		"received_bytes=" + strconv.FormatInt(interval.ReceivedBytes, 10) + "i",
+		"sent_bytes=" + strconv.FormatInt(interval.SentBytes, 10) + "i",
+		"qps=" + formatFloat(interval.QPS),
+		"avg_ms=" + formatFloat(interval.AverageTime),
+		"max_ms=" + formatFloat(interval.MaxTime),
+	}
+	for _, name := range percentileNames(interval) {
+		fields = append(fields, escapeInflux(name)+"_ms="+formatFloat(interval.Percentiles[name]))
+	}
+	lines = append(lines, fmt.Sprintf("%s%s %s %d", prefix, tags.String(), strings.Join(fields, ","), timestamp))
+	for _, code := range errCodes(interval) {
+		lines = append(lines, fmt.Sprintf("%s_code%s,code=%d count=%di %d", prefix, tags.String(), code,
+			interval.ErrCode[code], timestamp))
+	}
+	return
+}
+
+// percentileNames 百分位名称 按设置的百分位顺序
+func percentileNames(interval *statistics.Interval) (names []string) {
+	for _, percent := range statistics.Percentiles() {
+		name := statistics.PercentileName(percent)
+		if _, ok := interval.Percentiles[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+// errCodes 错误码 从小到大
+func errCodes(interval *statistics.Interval) (codes []int) {
+	for code := range interval.ErrCode {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return
+}
+
+// formatFloat 浮点数 保留3位小数
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 3, 64)
+}
+
+// escapeInflux 行协议的标签、字段名转义
+func escapeInflux(value string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(value)
+}
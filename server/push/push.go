@@ -0,0 +1,184 @@
+// Package push 将每个统计周期的数据推送到 StatsD、InfluxDB 等指标系统
+package push
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"goapistress/server/statistics"
+	"goapistress/tools"
+)
+
+// 推送格式
+const (
+	// FormatStatsD StatsD 格式 标签为 DogStatsD 格式
+	FormatStatsD = "statsd"
+	// FormatInflux InfluxDB 行协议
+	FormatInflux = "influx"
+)
+
+const (
+	// prefix 指标名前缀
+	prefix = "goapistress"
+	// bufferSize 等待推送的统计周期数，推送跟不上时丢弃
+	bufferSize = 100
+	// maxPacketSize UDP 单个包的最大字节数
+	maxPacketSize = 1400
+	// dialTimeout 连接超时时间
+	dialTimeout = 5 * time.Second
+)
+
+var (
+	// writeTimeout 写超时时间 接收方不读取时不一直阻塞
+	writeTimeout = 5 * time.Second
+	// closeTimeout 关闭时等待推送完缓冲中数据的最长时间
+	closeTimeout = 10 * time.Second
+)
+
+// Tag 标签
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// ParseTags 解析标签 示例: test=checkout,run=42
+func ParseTags(str string) (tags []Tag, err error) {
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		index := strings.Index(item, "=")
+		if index <= 0 {
+			return nil, fmt.Errorf("标签不合法:%s 示例:test=checkout", item)
+		}
+		tags = append(tags, Tag{Key: strings.TrimSpace(item[:index]), Value: strings.TrimSpace(item[index+1:])})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+	return
+}
+
+// Sink 推送统计周期数据 实现 statistics.IntervalSink
+// 数据先放入缓冲，由单独的协程推送，不阻塞统计
+type Sink struct {
+	format  string                    // 推送格式
+	network string                    // udp tcp
+	address string                    // 地址
+	tags    []Tag                     // 标签
+	conn    net.Conn                  // 连接
+	ch      chan *statistics.Interval // 等待推送的数据
+	done    chan struct{}             // 推送协程退出
+}
+
+// NewSink 推送统计周期数据
+// format 推送格式 statsd influx
+// address 推送地址 示例: udp://127.0.0.1:8125 tcp://127.0.0.1:8094，不指定协议时为 udp
+func NewSink(format, address string, tags []Tag) (sink *Sink, err error) {
+	if format != FormatStatsD && format != FormatInflux {
+		return nil, fmt.Errorf("推送格式不支持:%s 支持:statsd influx", format)
+	}
+	if !strings.Contains(address, "://") {
+		address = "udp://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("推送地址不合法:%s %v", address, err)
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("推送地址协议不支持:%s 支持:udp tcp", address)
+	}
+	sink = &Sink{
+		format:  format,
+		network: u.Scheme,
+		address: u.Host,
+		tags:    tags,
+		ch:      make(chan *statistics.Interval, bufferSize),
+		done:    make(chan struct{}),
+	}
+	sink.conn, err = net.DialTimeout(sink.network, sink.address, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("推送地址连接失败:%s %v", address, err)
+	}
+	go sink.run()
+	return sink, nil
+}
+
+// Write 放入推送缓冲 缓冲已满时丢弃
+func (s *Sink) Write(interval *statistics.Interval) error {
+	select {
+	case s.ch <- interval:
+		return nil
+	default:
+		return fmt.Errorf("%s 推送缓冲已满，丢弃一个统计周期的数据", s.format)
+	}
+}
+
+// Close 推送完缓冲中的数据以后关闭连接 超过 closeTimeout 时放弃推送剩余的数据，不阻塞退出
+func (s *Sink) Close() error {
+	close(s.ch)
+	select {
+	case <-s.done:
+	case <-time.After(closeTimeout):
+		return fmt.Errorf("%s 推送超时，放弃推送剩余的数据", s.format)
+	}
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// run 推送协程
+func (s *Sink) run() {
+	defer close(s.done)
+	for interval := range s.ch {
+		var lines []string
+		if s.format == FormatStatsD {
+			lines = s.statsD(interval)
+		} else {
+			lines = s.influx(interval)
+		}
+		if err := s.send(lines); err != nil {
+			tools.Println(s.format, "推送失败:", err)
+		}
+	}
+}
+
+// send 发送 UDP 按包大小拆分，TCP 断开时重连一次
+func (s *Sink) send(lines []string) (err error) {
+	var packets []string
+	if s.network == "udp" {
+		var packet strings.Builder
+		for _, line := range lines {
+			if packet.Len() > 0 && packet.Len()+len(line)+1 > maxPacketSize {
+				packets = append(packets, packet.String())
+				packet.Reset()
+			}
+			packet.WriteString(line)
+			packet.WriteString("\n")
+		}
+		packets = append(packets, packet.String())
+	} else {
+		packets = []string{strings.Join(lines, "\n") + "\n"}
+	}
+	for _, packet := range packets {
+		if s.conn == nil {
+			if s.conn, err = net.DialTimeout(s.network, s.address, dialTimeout); err != nil {
+				s.conn = nil
+				return
+			}
+		}
+		_ = s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err = s.conn.Write([]byte(packet)); err != nil {
+			_ = s.conn.Close()
+			s.conn = nil
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,138 @@
+// Package push 推送指标
+package push
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"goapistress/server/statistics"
+)
+
+// TestSink 测试推送到本地 UDP 监听
+func TestSink(t *testing.T) {
+	tags, err := ParseTags("test=checkout, run=42")
+	if err != nil {
+		t.Fatalf("标签解析失败:%v", err)
+	}
+	interval := &statistics.Interval{
+		Time:        time.Unix(1, 0),
+		Workers:     3,
+		SuccessNum:  10,
+		FailureNum:  1,
+		QPS:         10,
+		Percentiles: map[string]float64{"tp99": 12.5},
+		ErrCode:     map[int]int{200: 10, 500: 1},
+	}
+	tt := map[string]struct {
+		format string
+		lines  []string
+	}{
+		"statsd": {format: FormatStatsD, lines: []string{
+			"goapistress.requests.success:10|c|#run:42,test:checkout",
+			"goapistress.latency.tp99_ms:12.500|g|#run:42,test:checkout",
+			"goapistress.requests.code:1|c|#run:42,test:checkout,code:500",
+		}},
+		"influx": {format: FormatInflux, lines: []string{
+			"goapistress,run=42,test=checkout workers=3i,success=10i,failure=1i,dropped=0i,received_bytes=0i,sent_bytes=0i," +
+				"qps=10.000,avg_ms=0.000,max_ms=0.000,tp99_ms=12.500 1000000000",
+			"goapistress_code,run=42,test=checkout,code=500 count=1i 1000000000",
+		}},
+	}
+	for name, value := range tt {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("监听失败:%v", err)
+		}
+		sink, err := NewSink(value.format, conn.LocalAddr().String(), tags)
+		if err != nil {
+			t.Fatalf("%s 连接失败:%v", name, err)
+		}
+		if err = sink.Write(interval); err != nil {
+			t.Fatalf("%s 推送失败:%v", name, err)
+		}
+		_ = sink.Close()
+		buf := make([]byte, maxPacketSize)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		_ = conn.Close()
+		if err != nil {
+			t.Fatalf("%s 接收失败:%v", name, err)
+		}
+		for _, line := range value.lines {
+			if !strings.Contains(string(buf[:n]), line+"\n") {
+				t.Errorf("%s 数据不一致 缺少:%s 实际:%s", name, line, buf[:n])
+			}
+		}
+	}
+}
+
+// TestSinkTCP 测试推送到本地 TCP 监听 接收方不读取时关闭不一直阻塞
+func TestSinkTCP(t *testing.T) {
+	defer func(timeout time.Duration) {
+		writeTimeout = timeout
+	}(writeTimeout)
+	writeTimeout = 100 * time.Millisecond
+	interval := &statistics.Interval{Time: time.Unix(1, 0), SuccessNum: 10, ErrCode: map[int]int{200: 10}}
+	// 错误码很多时一个统计周期有几 MB，写满接收方的缓冲
+	large := &statistics.Interval{Time: time.Unix(1, 0), ErrCode: make(map[int]int)}
+	for code := 0; code < 100000; code++ {
+		large.ErrCode[code] = 1
+	}
+	tt := map[string]struct {
+		interval *statistics.Interval
+		read     bool   // 接收方是否读取
+		line     string // 接收到的数据
+	}{
+		"tcp":     {interval: interval, read: true, line: "goapistress.requests.success:10|c\n"},
+		"stalled": {interval: large},
+	}
+	for name, value := range tt {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("监听失败:%v", err)
+		}
+		received := make(chan string, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if !value.read {
+				// 不读取 直到测试结束
+				<-received
+				return
+			}
+			data, _ := io.ReadAll(conn)
+			received <- string(data)
+		}()
+		sink, err := NewSink(FormatStatsD, "tcp://"+listener.Addr().String(), nil)
+		if err != nil {
+			t.Fatalf("%s 连接失败:%v", name, err)
+		}
+		if err = sink.Write(value.interval); err != nil {
+			t.Fatalf("%s 推送失败:%v", name, err)
+		}
+		startTime := time.Now()
+		_ = sink.Close()
+		if spent := time.Since(startTime); spent > 2*time.Second {
+			t.Errorf("%s 关闭耗时 数据不一致 预期:<%v 实际:%v", name, 2*time.Second, spent)
+		}
+		if value.read {
+			select {
+			case data := <-received:
+				if !strings.Contains(data, value.line) {
+					t.Errorf("%s 数据不一致 缺少:%s 实际:%s", name, value.line, data)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, value.line, "没有接收到数据")
+			}
+		} else {
+			close(received)
+		}
+		_ = listener.Close()
+	}
+}
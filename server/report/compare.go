@@ -0,0 +1,305 @@
+// Package report 压测报告
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"goapistress/server/statistics"
+)
+
+// Tolerance 对比的容差 超出容差且变化显著时判定为回退
+type Tolerance struct {
+	QPS        float64 // qps 允许下降的百分比
+	ErrorRate  float64 // 错误率允许上升的百分点
+	Latency    float64 // 耗时百分位允许上升的百分比
+	Confidence float64 // 显著性检验的置信度 0~1
+}
+
+// 对比结果
+const (
+	verdictOK         = "正常"
+	verdictImproved   = "提升"
+	verdictRegressed  = "回退"
+	verdictNoise      = "不显著" // 超出容差但变化不显著
+	verdictOnlyBase   = "只在基线中"
+	verdictOnlyTarget = "只在本次中"
+)
+
+// compareReport 对比需要的 JSON 报告内容 基线文件可以只包含部分字段
+type compareReport struct {
+	Summary   *jsonSummary           `json:"summary"`   // 整体结果
+	Endpoints []*jsonSummary         `json:"endpoints"` // 分接口结果
+	Intervals []*statistics.Interval `json:"intervals"` // 每个统计周期的数据
+}
+
+// compareRow 一个指标的对比
+type compareRow struct {
+	metric   string  // 指标
+	base     float64 // 基线
+	current  float64 // 本次
+	delta    string  // 变化
+	verdict  string  // 结果
+	testable bool    // 是否做了显著性检验
+}
+
+// readCompareReport 读取 JSON 报告
+func readCompareReport(path string) (report *compareReport, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report = &compareReport{}
+	if err = json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("%s 不是 JSON 报告:%v", path, err)
+	}
+	if report.Summary == nil {
+		return nil, fmt.Errorf("%s 中没有 summary", path)
+	}
+	return report, nil
+}
+
+// Compare 对比基线和本次压测的 JSON 报告(-report-json 输出)，按接口对齐，输出吞吐量、错误率、
+// 耗时百分位的变化，返回是否有超出容差且显著的回退
+func Compare(w io.Writer, baselinePath, currentPath string, tolerance Tolerance) (regressed bool, err error) {
+	if tolerance.Confidence <= 0 || tolerance.Confidence >= 1 {
+		return false, fmt.Errorf("置信度不合法:%v 取值 0~1", tolerance.Confidence)
+	}
+	baseline, err := readCompareReport(baselinePath)
+	if err != nil {
+		return
+	}
+	current, err := readCompareReport(currentPath)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "基线:%s 本次:%s\n", baselinePath, currentPath)
+	fmt.Fprintf(w, "容差 qps 下降:%v%% 错误率上升:%v 个百分点 耗时上升:%v%% 置信度:%v\n",
+		tolerance.QPS, tolerance.ErrorRate, tolerance.Latency, tolerance.Confidence)
+	zCritical := math.Sqrt2 * math.Erfinv(2*tolerance.Confidence-1)
+	// 整体结果有每秒数据，可以检验 qps 的变化
+	rows := compareSummary(baseline.Summary, current.Summary, tolerance, zCritical)
+	if qps := rows[0]; qps.verdict == verdictRegressed || qps.verdict == verdictNoise {
+		z, ok := welch(intervalQPS(baseline.Intervals), intervalQPS(current.Intervals))
+		qps.testable = ok
+		qps.verdict = significance(ok && -z > zCritical, !ok)
+	}
+	regressed = printCompare(w, "合计", rows) || regressed
+
+	baseEndpoints := make(map[string]*jsonSummary)
+	for _, endpoint := range baseline.Endpoints {
+		baseEndpoints[endpoint.Name] = endpoint
+	}
+	currentEndpoints := make(map[string]*jsonSummary)
+	for _, endpoint := range current.Endpoints {
+		currentEndpoints[endpoint.Name] = endpoint
+	}
+	// 只有一个接口时与合计相同，不再输出
+	if len(baseEndpoints) <= 1 && len(currentEndpoints) <= 1 {
+		return
+	}
+	var names []string
+	for name := range baseEndpoints {
+		names = append(names, name)
+	}
+	for name := range currentEndpoints {
+		if _, ok := baseEndpoints[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		base, ok1 := baseEndpoints[name]
+		target, ok2 := currentEndpoints[name]
+		switch {
+		case !ok1:
+			fmt.Fprintf(w, "\n接口:%s %s\n", name, verdictOnlyTarget)
+		case !ok2:
+			fmt.Fprintf(w, "\n接口:%s %s\n", name, verdictOnlyBase)
+		default:
+			regressed = printCompare(w, name, compareSummary(base, target, tolerance, zCritical)) || regressed
+		}
+	}
+	return
+}
+
+// compareSummary 对比一个接口 第一行为 qps，分接口没有每秒数据，qps 只按容差判定
+func compareSummary(base, current *jsonSummary, tolerance Tolerance, zCritical float64) (rows []*compareRow) {
+	qps := &compareRow{metric: "qps", base: base.Throughput.QPS, current: current.Throughput.QPS}
+	qps.delta = relative(qps.base, qps.current)
+	qps.verdict = verdictOK
+	if change := percentChange(qps.base, qps.current); change < -tolerance.QPS {
+		qps.verdict = verdictRegressed
+	} else if change > tolerance.QPS {
+		qps.verdict = verdictImproved
+	}
+	rows = append(rows, qps)
+
+	errorRate := &compareRow{metric: "error_rate(%)", base: base.ErrorRate, current: current.ErrorRate}
+	errorRate.delta = fmt.Sprintf("%+.2f", current.ErrorRate-base.ErrorRate)
+	errorRate.verdict = verdictOK
+	if change := current.ErrorRate - base.ErrorRate; change > tolerance.ErrorRate {
+		z, ok := twoProportion(base.FailureNum, base.Total, current.FailureNum, current.Total)
+		errorRate.testable = ok
+		errorRate.verdict = significance(ok && z > zCritical, !ok)
+	} else if -change > tolerance.ErrorRate {
+		errorRate.verdict = verdictImproved
+	}
+	rows = append(rows, errorRate)
+
+	if base.Latency == nil || current.Latency == nil {
+		return
+	}
+	for _, name := range percentileNames(base.Latency.Percentiles, current.Latency.Percentiles) {
+		row := &compareRow{metric: name + "(ms)", base: base.Latency.Percentiles[name],
+			current: current.Latency.Percentiles[name]}
+		row.delta = relative(row.base, row.current)
+		row.verdict = verdictOK
+		if change := percentChange(row.base, row.current); change > tolerance.Latency {
+			// 每个百分位单独检验 只有尾部变慢时整体分布的检验不显著
+			z, ok := quantileTest(base.Latency.Buckets, current.Latency.Buckets, row.base)
+			row.testable = ok
+			row.verdict = significance(ok && z > zCritical, !ok)
+		} else if change < -tolerance.Latency {
+			row.verdict = verdictImproved
+		}
+		rows = append(rows, row)
+	}
+	return
+}
+
+// significance 超出容差时的结果 无法检验时按回退处理
+func significance(significant, untestable bool) string {
+	if significant || untestable {
+		return verdictRegressed
+	}
+	return verdictNoise
+}
+
+// printCompare 输出一个接口的对比 返回是否有回退
+func printCompare(w io.Writer, name string, rows []*compareRow) (regressed bool) {
+	fmt.Fprintf(w, "\n接口:%s\n", name)
+	fmt.Fprintln(w, "            指标│        基线│        本次│     变化│ 结果")
+	for _, row := range rows {
+		verdict := row.verdict
+		if row.verdict == verdictRegressed {
+			regressed = true
+			if !row.testable {
+				verdict = verdict + "(未做显著性检验)"
+			}
+		}
+		fmt.Fprintf(w, "%16s│%12.3f│%12.3f│%9s│ %s\n", row.metric, row.base, row.current, row.delta, verdict)
+	}
+	return
+}
+
+// percentChange 变化的百分比 基线为0时本次不为0按100%计算
+func percentChange(base, current float64) float64 {
+	if base == 0 {
+		if current == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (current - base) * 100 / base
+}
+
+// relative 变化的百分比 示例: +12.50%
+func relative(base, current float64) string {
+	return fmt.Sprintf("%+.2f%%", percentChange(base, current))
+}
+
+// percentileNames 两次都有的百分位 从小到大
+func percentileNames(base, current map[string]float64) (names []string) {
+	for name := range base {
+		if _, ok := current[name]; ok {
+			names = append(names, name)
+		}
+	}
+	percent := func(name string) float64 {
+		value, _ := strconv.ParseFloat(strings.TrimPrefix(name, "tp"), 64)
+		return value
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return percent(names[i]) < percent(names[j])
+	})
+	return
+}
+
+// intervalQPS 每个统计周期的 qps 去掉最后一个不足一秒的周期
+func intervalQPS(intervals []*statistics.Interval) (values []float64) {
+	for i, interval := range intervals {
+		if i == len(intervals)-1 && i > 0 && interval.Elapsed-intervals[i-1].Elapsed < 0.9 {
+			break
+		}
+		values = append(values, interval.QPS)
+	}
+	return
+}
+
+// welch Welch t 检验 样本较多时按正态分布近似，返回本次相对基线的 z 值，样本不足时 ok 为 false
+func welch(base, current []float64) (z float64, ok bool) {
+	if len(base) < 2 || len(current) < 2 {
+		return 0, false
+	}
+	mean1, var1 := meanVariance(base)
+	mean2, var2 := meanVariance(current)
+	se := math.Sqrt(var1/float64(len(base)) + var2/float64(len(current)))
+	if se == 0 {
+		if mean1 == mean2 {
+			return 0, true
+		}
+		return math.Copysign(math.Inf(1), mean2-mean1), true
+	}
+	return (mean2 - mean1) / se, true
+}
+
+// meanVariance 平均值、样本方差
+func meanVariance(values []float64) (mean, variance float64) {
+	for _, value := range values {
+		mean = mean + value
+	}
+	mean = mean / float64(len(values))
+	for _, value := range values {
+		variance = variance + (value-mean)*(value-mean)
+	}
+	return mean, variance / float64(len(values)-1)
+}
+
+// twoProportion 两个比例的 z 检验 返回本次相对基线的 z 值，没有请求数时 ok 为 false
+func twoProportion(failure1, total1, failure2, total2 uint64) (z float64, ok bool) {
+	if total1 == 0 || total2 == 0 {
+		return 0, false
+	}
+	p1 := float64(failure1) / float64(total1)
+	p2 := float64(failure2) / float64(total2)
+	p := float64(failure1+failure2) / float64(total1+total2)
+	se := math.Sqrt(p * (1 - p) * (1/float64(total1) + 1/float64(total2)))
+	if se == 0 {
+		return 0, true
+	}
+	return (p2 - p1) / se, true
+}
+
+// quantileTest 百分位的检验 按直方图区间统计两次压测耗时超过基线百分位(cut 毫秒)的请求数，做两比例 z 检验，
+// 本次的百分位变慢时超过的比例变大，返回本次比基线慢的 z 值，没有直方图时 ok 为 false
+func quantileTest(base, current []jsonBucket, cut float64) (z float64, ok bool) {
+	above := func(buckets []jsonBucket) (count, total uint64) {
+		for _, bucket := range buckets {
+			if bucket.From > cut {
+				count = count + bucket.Count
+			}
+			total = total + bucket.Count
+		}
+		return
+	}
+	above1, total1 := above(base)
+	above2, total2 := above(current)
+	return twoProportion(above1, total1, above2, total2)
+}
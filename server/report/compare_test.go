@@ -0,0 +1,91 @@
+// Package report 压测报告
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goapistress/server/statistics"
+)
+
+// uniformLatency 耗时分布 1000个请求中990个落在 latency 毫秒
+func uniformLatency(latency float64) *jsonLatency {
+	return &jsonLatency{
+		Percentiles: map[string]float64{"tp99": latency},
+		Buckets:     []jsonBucket{{From: latency, To: latency, Count: 1000 - 10}, {From: 1, To: 1, Count: 10}},
+	}
+}
+
+// writeCompareReport 输出对比用的 JSON 报告 共1000个请求，qps 每秒数据都为 qps
+func writeCompareReport(t *testing.T, name string, qps float64, latency *jsonLatency, failure uint64) string {
+	report := &compareReport{
+		Summary: &jsonSummary{
+			Total:      1000,
+			FailureNum: failure,
+			ErrorRate:  float64(failure) / 10,
+			Throughput: jsonThroughput{QPS: qps},
+			Latency:    latency,
+		},
+	}
+	for i := 1; i <= 10; i++ {
+		report.Intervals = append(report.Intervals, &statistics.Interval{Elapsed: float64(i), QPS: qps + float64(i%2)})
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestCompare 测试超出容差且显著时判定为回退
+func TestCompare(t *testing.T) {
+	tolerance := Tolerance{QPS: 5, ErrorRate: 0.1, Latency: 10, Confidence: 0.95}
+	base := writeCompareReport(t, "base.json", 1000, uniformLatency(100), 0)
+	tt := map[string]struct {
+		qps       float64
+		latency   float64
+		failure   uint64
+		regressed bool
+	}{
+		"same":      {qps: 1000, latency: 100},
+		"faster":    {qps: 1200, latency: 50},
+		"slower":    {qps: 1000, latency: 150, regressed: true},
+		"lowQPS":    {qps: 800, latency: 100, regressed: true},
+		"errorRate": {qps: 1000, latency: 100, failure: 100, regressed: true},
+		"noise":     {qps: 1000, latency: 100, failure: 2},
+	}
+	for name, value := range tt {
+		current := writeCompareReport(t, name+".json", value.qps, uniformLatency(value.latency), value.failure)
+		regressed, err := Compare(io.Discard, base, current, tolerance)
+		if err != nil {
+			t.Fatalf("%s 对比失败:%v", name, err)
+		}
+		if regressed != value.regressed {
+			t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, value.regressed, regressed)
+		}
+	}
+
+	// 只有尾部变慢 大部分请求变快，整体分布没有变慢，tp99 单独检验判定为回退
+	base = writeCompareReport(t, "tailBase.json", 1000, &jsonLatency{
+		Percentiles: map[string]float64{"tp50": 10, "tp99": 10},
+		Buckets:     []jsonBucket{{From: 10, To: 10, Count: 990}, {From: 20, To: 20, Count: 10}},
+	}, 0)
+	current := writeCompareReport(t, "tail.json", 1000, &jsonLatency{
+		Percentiles: map[string]float64{"tp50": 5, "tp99": 100},
+		Buckets:     []jsonBucket{{From: 5, To: 5, Count: 500}, {From: 10, To: 10, Count: 450}, {From: 100, To: 100, Count: 50}},
+	}, 0)
+	regressed, err := Compare(io.Discard, base, current, tolerance)
+	if err != nil {
+		t.Fatalf("tail 对比失败:%v", err)
+	}
+	if !regressed {
+		t.Errorf("tail 数据不一致 预期:%v 实际:%v", true, regressed)
+	}
+}
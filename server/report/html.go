@@ -0,0 +1,402 @@
+// Package report 压测报告
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"goapistress/model"
+	"goapistress/server/statistics"
+)
+
+const (
+	// chartWidth 图表宽度
+	chartWidth = 860
+	// chartHeight 图表高度
+	chartHeight = 260
+	// chartPadding 图表坐标轴留白
+	chartPadding = 50
+	// histogramBins 耗时直方图的区间数
+	histogramBins = 40
+)
+
+// chartColors 图表颜色
+var chartColors = []string{"#2f7ed8", "#0d233a", "#8bbc21", "#910000", "#1aadce", "#492970", "#f28f43",
+	"#77a1e5", "#c42525", "#a6c96a"}
+
+// series 折线图的一条线
+type series struct {
+	name   string    // 名称
+	values []float64 // 与横轴一一对应
+}
+
+// htmlReport HTML 报告模板数据
+type htmlReport struct {
+	Time       string
+	Request    string
+	Task       []htmlRow
+	Summary    []htmlRow
+	Endpoints  []*statistics.Result
+	Codes      []*statistics.Breakdown
+	Titles     []string
+	Throughput template.HTML
+	Latency    template.HTML
+	Histogram  template.HTML
+	ErrCode    template.HTML
+}
+
+// htmlRow 键值对
+type htmlRow struct {
+	Key   string
+	Value string
+}
+
+// WriteHTML 输出离线可查看的 HTML 压测报告 图表为内嵌的 SVG，不依赖外部资源
+func WriteHTML(path string, task *model.TaskForm, request *model.RequestForm, result *statistics.Result) error {
+	percentiles := statistics.Percentiles()
+	report := &htmlReport{
+		Time:      time.Now().Format("2006-01-02 15:04:05"),
+		Request:   redactRequest(request).String(),
+		Task:      taskRows(task),
+		Endpoints: endpoints(request, result),
+		Codes:     append(result.Outcomes, result.Codes...),
+	}
+	for _, percent := range percentiles {
+		report.Titles = append(report.Titles, statistics.PercentileName(percent))
+	}
+	report.Summary = []htmlRow{
+		{"请求总数", fmt.Sprintf("%d", result.Total())},
+		{"成功数", fmt.Sprintf("%d", result.SuccessNum)},
+		{"失败数", fmt.Sprintf("%d", result.FailureNum)},
+		{"错误率", fmt.Sprintf("%.2f%%", result.ErrorRate())},
+		{"压测时长", fmt.Sprintf("%.3f 秒", float64(result.RequestTime)/1e9)},
+		{"qps", fmt.Sprintf("%.2f", result.QPS)},
+		{"平均耗时", fmt.Sprintf("%.3f ms", float64(result.AverageTime())/1e6)},
+		{"最长耗时", fmt.Sprintf("%.3f ms", float64(result.MaxTime)/1e6)},
+		{"最短耗时", fmt.Sprintf("%.3f ms", float64(result.MinTime)/1e6)},
+		{"下载字节", transfer(result.ReceivedBytes, result.RequestTime)},
+		{"上传字节", transfer(result.SentBytes, result.RequestTime)},
+	}
+	values := result.Latency().Percentiles(percentiles)
+	for i, name := range report.Titles {
+		report.Summary = append(report.Summary, htmlRow{name, fmt.Sprintf("%.3f ms", float64(values[i])/1e6)})
+	}
+	if result.Dropped > 0 {
+		report.Summary = append(report.Summary, htmlRow{"丢弃数", fmt.Sprintf("%d", result.Dropped)})
+	}
+	if result.WarmUpNum > 0 {
+		report.Summary = append(report.Summary, htmlRow{"预热请求数(不计入统计)", fmt.Sprintf("%d", result.WarmUpNum)})
+	}
+	if result.AbortReason != "" {
+		report.Summary = append(report.Summary, htmlRow{"提前结束", result.AbortReason})
+	}
+
+	// 随时间变化的吞吐量、耗时百分位
+	var (
+		elapsed = make([]float64, len(result.Intervals))
+		qps     = series{name: "qps"}
+		failure = series{name: "失败数/秒"}
+		latency = make([]series, len(percentiles))
+	)
+	for i, name := range report.Titles {
+		latency[i].name = name
+	}
+	for i, interval := range result.Intervals {
+		elapsed[i] = interval.Elapsed
+		qps.values = append(qps.values, interval.QPS)
+		seconds := interval.Elapsed
+		if i > 0 {
+			seconds = seconds - result.Intervals[i-1].Elapsed
+		}
+		if seconds > 0 {
+			failure.values = append(failure.values, float64(interval.FailureNum)/seconds)
+		} else {
+			failure.values = append(failure.values, 0)
+		}
+		for j, name := range report.Titles {
+			latency[j].values = append(latency[j].values, interval.Percentiles[name])
+		}
+	}
+	report.Throughput = lineChart("每秒请求数", "秒", elapsed, []series{qps, failure})
+	report.Latency = lineChart("耗时百分位(ms)", "秒", elapsed, latency)
+	report.Histogram = histogramChart(result.Latency())
+	report.ErrCode = errCodeChart(result.ErrCode)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	return htmlTemplate.Execute(file, report)
+}
+
+// transfer 传输字节和每秒字节
+func transfer(bytes int64, requestTime uint64) string {
+	if requestTime == 0 {
+		return fmt.Sprintf("%d", bytes)
+	}
+	return fmt.Sprintf("%d (%.0f/s)", bytes, float64(bytes)*1e9/float64(requestTime))
+}
+
+// taskRows 压测任务参数
+func taskRows(task *model.TaskForm) (rows []htmlRow) {
+	if task == nil {
+		return
+	}
+	rows = append(rows, htmlRow{"并发数", fmt.Sprintf("%d", task.Concurrency)})
+	if task.Number > 0 {
+		rows = append(rows, htmlRow{"请求数(单个并发/协程)", fmt.Sprintf("%d", task.Number)})
+	}
+	if task.Duration > 0 {
+		rows = append(rows, htmlRow{"压测时长", task.Duration.String()})
+	}
+	if task.IsOpen() {
+		rows = append(rows, htmlRow{"到达速率", fmt.Sprintf("%v/s", task.Rate)},
+			htmlRow{"最大并发", fmt.Sprintf("%d", task.MaxInFlight)})
+	}
+	if task.IsStaged() {
+		rows = append(rows, htmlRow{"分阶段压测", task.Stages.String()})
+	}
+	if task.WarmUp > 0 || task.WarmUpNumber > 0 {
+		rows = append(rows, htmlRow{"预热", fmt.Sprintf("时长:%s 请求数:%d", task.WarmUp, task.WarmUpNumber)})
+	}
+	if task.Pacing > 0 || task.ThinkTime != nil {
+		rows = append(rows, htmlRow{"节奏/思考时间", fmt.Sprintf("%s / %s", task.Pacing, task.ThinkTime)})
+	}
+	return
+}
+
+// lineChart 折线图
+func lineChart(title, unit string, x []float64, list []series) template.HTML {
+	if len(x) == 0 {
+		return template.HTML("<p>无数据</p>")
+	}
+	// 横轴为压测耗时 递增
+	maxX, maxY := x[len(x)-1], 0.0
+	for _, s := range list {
+		for _, value := range s.values {
+			maxY = math.Max(maxY, value)
+		}
+	}
+	maxY = niceMax(maxY)
+	if maxX <= 0 {
+		maxX = 1
+	}
+	var b strings.Builder
+	b.WriteString(svgHeader(title))
+	b.WriteString(yAxis(maxY))
+	for i := 0; i <= 4; i++ {
+		value := maxX * float64(i) / 4
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%.1f%s</text>`, scaleX(value, maxX),
+			chartHeight-chartPadding+16, value, unit)
+	}
+	for i, s := range list {
+		points := make([]string, len(s.values))
+		for j, value := range s.values {
+			points[j] = fmt.Sprintf("%.1f,%.1f", scaleX(x[j], maxX), scaleY(value, maxY))
+		}
+		color := chartColors[i%len(chartColors)]
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`, color,
+			strings.Join(points, " "))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/><text x="%d" y="%d">%s</text>`,
+			chartWidth-chartPadding-120, 20+i*14, color, chartWidth-chartPadding-105, 29+i*14, html.EscapeString(s.name))
+	}
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+// histogramChart 耗时直方图 横轴为对数刻度
+func histogramChart(histogram *statistics.Histogram) template.HTML {
+	buckets := histogram.Buckets()
+	if len(buckets) == 0 {
+		return template.HTML("<p>无数据</p>")
+	}
+	lowest := math.Max(float64(buckets[0].Lowest), 1)
+	highest := math.Max(float64(buckets[len(buckets)-1].Highest), lowest+1)
+	counts := make([]uint64, histogramBins)
+	ratio := math.Log(highest / lowest)
+	for _, bucket := range buckets {
+		middle := math.Max((float64(bucket.Lowest)+float64(bucket.Highest))/2, lowest)
+		index := int(math.Log(middle/lowest) / ratio * histogramBins)
+		if index >= histogramBins {
+			index = histogramBins - 1
+		}
+		counts[index] = counts[index] + bucket.Count
+	}
+	var maxY float64
+	for _, count := range counts {
+		maxY = math.Max(maxY, float64(count))
+	}
+	maxY = niceMax(maxY)
+	var b strings.Builder
+	b.WriteString(svgHeader("耗时分布(ms 对数刻度)"))
+	b.WriteString(yAxis(maxY))
+	width := float64(chartWidth-2*chartPadding) / histogramBins
+	for i, count := range counts {
+		x := float64(chartPadding) + float64(i)*width
+		y := scaleY(float64(count), maxY)
+		from := lowest * math.Exp(ratio*float64(i)/histogramBins) / 1e6
+		to := lowest * math.Exp(ratio*float64(i+1)/histogramBins) / 1e6
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%.3f~%.3fms: %d</title></rect>`,
+			x+1, y, width-2, float64(chartHeight-chartPadding)-y, chartColors[0], from, to, count)
+		if i%8 == 0 {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%.3g</text>`, x, chartHeight-chartPadding+16, from)
+		}
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%.3g</text>`, chartWidth-chartPadding,
+		chartHeight-chartPadding+16, highest/1e6)
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+// errCodeChart 错误码分布 横向条形图
+func errCodeChart(errCode map[int]int) template.HTML {
+	if len(errCode) == 0 {
+		return template.HTML("<p>无数据</p>")
+	}
+	codes := make([]int, 0, len(errCode))
+	var total, maxCount int
+	for code, count := range errCode {
+		codes = append(codes, code)
+		total = total + count
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+	sort.Ints(codes)
+	height := 30 + len(codes)*24
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" xmlns="http://www.w3.org/2000/svg">`, chartWidth, height)
+	for i, code := range codes {
+		count := errCode[code]
+		y := 10 + i*24
+		width := float64(chartWidth-2*chartPadding-200) * float64(count) / float64(maxCount)
+		color := chartColors[0]
+		if code != model.HTTPOk {
+			color = chartColors[3]
+		}
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%d</text>`, chartPadding+20, y+14, code)
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="18" fill="%s"/>`, chartPadding+30, y, width, color)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d">%d (%.2f%%) %s</text>`, float64(chartPadding+36)+width, y+14, count,
+			float64(count)*100/float64(total), model.ErrName(code))
+	}
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+// svgHeader 图表开头 标题和坐标轴
+func svgHeader(title string) string {
+	return fmt.Sprintf(`<svg width="%d" height="%d" xmlns="http://www.w3.org/2000/svg">`+
+		`<text x="%d" y="20" font-weight="bold">%s</text>`+
+		`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`+
+		`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`,
+		chartWidth, chartHeight, chartPadding, html.EscapeString(title),
+		chartPadding, chartHeight-chartPadding, chartWidth-chartPadding, chartHeight-chartPadding,
+		chartPadding, chartPadding/2, chartPadding, chartHeight-chartPadding)
+}
+
+// yAxis 纵轴刻度和网格线
+func yAxis(maxY float64) string {
+	var b strings.Builder
+	for i := 1; i <= 4; i++ {
+		value := maxY * float64(i) / 4
+		y := scaleY(value, maxY)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`, chartPadding, y,
+			chartWidth-chartPadding, y)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%.4g</text>`, chartPadding-4, y+4, value)
+	}
+	return b.String()
+}
+
+// scaleX 横轴坐标
+func scaleX(value, maxX float64) float64 {
+	return chartPadding + value/maxX*float64(chartWidth-2*chartPadding)
+}
+
+// scaleY 纵轴坐标
+func scaleY(value, maxY float64) float64 {
+	return float64(chartHeight-chartPadding) - value/maxY*float64(chartHeight-chartPadding-chartPadding/2)
+}
+
+// niceMax 纵轴最大值 取整到 1、2、5 的倍数
+func niceMax(value float64) float64 {
+	if value <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if value <= step*magnitude {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// htmlTemplate HTML 报告模板
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms": func(value uint64) string {
+		return fmt.Sprintf("%.3f", float64(value)/1e6)
+	},
+	"percentiles": func(histogram *statistics.Histogram) (values []string) {
+		for _, value := range histogram.Percentiles(statistics.Percentiles()) {
+			values = append(values, fmt.Sprintf("%.3f", float64(value)/1e6))
+		}
+		return
+	},
+	"errCode": errCodeString,
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>压测报告 {{.Time}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 22px; } h2 { font-size: 17px; margin-top: 28px; border-bottom: 1px solid #ddd; padding-bottom: 4px; }
+table { border-collapse: collapse; font-size: 13px; }
+td, th { border: 1px solid #ddd; padding: 4px 10px; text-align: right; }
+th { background: #f5f5f5; } td.key, td.name { text-align: left; }
+pre { background: #f8f8f8; padding: 10px; font-size: 12px; overflow-x: auto; }
+svg text { font-size: 11px; fill: #333; }
+</style>
+</head>
+<body>
+<h1>压测报告</h1>
+<p>生成时间: {{.Time}}</p>
+<h2>汇总</h2>
+<table>
+{{range .Summary}}<tr><td class="key">{{.Key}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+<h2>吞吐量</h2>
+{{.Throughput}}
+<h2>耗时百分位</h2>
+{{.Latency}}
+<h2>耗时分布</h2>
+{{.Histogram}}
+<h2>错误码</h2>
+{{.ErrCode}}
+<h2>分接口统计(耗时 ms)</h2>
+<table>
+<tr><th>接口</th><th>请求数</th><th>成功数</th><th>失败数</th><th>qps</th><th>错误率</th><th>平均耗时</th><th>最长耗时</th>{{range .Titles}}<th>{{.}}</th>{{end}}<th>状态码</th></tr>
+{{range .Endpoints}}<tr><td class="name">{{.Name}}</td><td>{{.Total}}</td><td>{{.SuccessNum}}</td><td>{{.FailureNum}}</td><td>{{printf "%.2f" .QPS}}</td><td>{{printf "%.2f" .ErrorRate}}%</td><td>{{ms .AverageTime}}</td><td>{{ms .MaxTime}}</td>{{range percentiles .Latency}}<td>{{.}}</td>{{end}}<td>{{errCode .ErrCode}}</td></tr>
+{{end}}</table>
+{{if .Codes}}<h2>按成功/失败、错误码统计(耗时 ms，大小 字节)</h2>
+<table>
+<tr><th>分组</th><th>请求数</th><th>平均耗时</th><th>最长耗时</th>{{range .Titles}}<th>{{.}}</th>{{end}}<th>平均大小</th><th>最小大小</th><th>最大大小</th></tr>
+{{range .Codes}}<tr><td class="name">{{.Name}}</td><td>{{.Count}}</td><td>{{ms .Latency.Mean}}</td><td>{{ms .Latency.Max}}</td>{{range percentiles .Latency}}<td>{{.}}</td>{{end}}<td>{{printf "%.0f" .AverageBytes}}</td><td>{{.MinBytes}}</td><td>{{.MaxBytes}}</td></tr>
+{{end}}</table>{{end}}
+<h2>压测参数</h2>
+<table>
+{{range .Task}}<tr><td class="key">{{.Key}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+<pre>{{.Request}}</pre>
+</body>
+</html>
+`))
@@ -0,0 +1,63 @@
+// Package report 压测报告
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"goapistress/model"
+	"goapistress/server/statistics"
+)
+
+// TestWriteHTML 测试图表、表格、没有数据时的提示 请求头脱敏
+func TestWriteHTML(t *testing.T) {
+	task := &model.TaskForm{Concurrency: 2, Number: 10, Duration: 2 * time.Second}
+	request := &model.RequestForm{URL: "http://127.0.0.1/", MP: model.MPTypeHTTP, Method: "GET",
+		Headers: map[string]string{"Authorization": "Bearer abc"}}
+	empty := make(chan *model.RequestResults)
+	close(empty)
+	tt := map[string]struct {
+		result   *statistics.Result
+		contains []string
+		noData   int // "无数据" 出现的次数 吞吐量、耗时百分位、耗时分布、错误码
+	}{
+		"result": {result: newTestResult(task), contains: []string{
+			"<polyline",                          // 吞吐量、耗时百分位折线
+			"ms: ",                               // 耗时分布区间
+			`text-anchor="end">500</text>`,       // 错误码
+			`<td class="name">a</td>`,            // 分接口统计
+			`<td class="name">b</td>`,            // 分接口统计
+			"按成功/失败、错误码统计",                       // 按错误码分组
+			`<td class="name">failure</td>`,      // 按成功/失败分组
+			"<th>tp99</th>",                      // 百分位表头
+			`<td class="key">并发数</td><td>2</td>`, // 压测参数
+		}},
+		"empty": {result: statistics.ReceivingResults(task, &model.TaskStatus{},
+			statistics.NewWarmUp(task, time.Now()), empty), noData: 4},
+	}
+	for name, value := range tt {
+		path := filepath.Join(t.TempDir(), name+".html")
+		if err := WriteHTML(path, task, request, value.result); err != nil {
+			t.Fatalf("%s 输出失败:%v", name, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s 读取失败:%v", name, err)
+		}
+		html := string(data)
+		for _, str := range value.contains {
+			if !strings.Contains(html, str) {
+				t.Errorf("%s 数据不一致 缺少:%s", name, str)
+			}
+		}
+		if count := strings.Count(html, "<p>无数据</p>"); count != value.noData {
+			t.Errorf("%s 数据不一致 无数据 预期:%d 实际:%d", name, value.noData, count)
+		}
+		if strings.Contains(html, "Bearer abc") {
+			t.Errorf("%s 数据不一致 请求头没有脱敏", name)
+		}
+	}
+}
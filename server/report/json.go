@@ -0,0 +1,329 @@
+// Package report 压测报告
+package report
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"time"
+
+	"goapistress/model"
+	"goapistress/server/statistics"
+)
+
+// bucketDigits 报告中直方图区间的有效数字位数 相邻的子桶按该精度合并，避免报告过大
+const bucketDigits = 2
+
+// jsonReport JSON 格式压测报告 时长都为毫秒
+type jsonReport struct {
+	Time       time.Time              `json:"time"`                   // 报告生成时间
+	Request    *jsonRequest           `json:"request"`                // 请求参数
+	Task       *jsonTask              `json:"task"`                   // 压测任务参数
+	Passed     bool                   `json:"passed"`                 // 是否通过判定
+	Abort      string                 `json:"abort_reason,omitempty"` // 提前结束压测的原因
+	Thresholds []jsonThreshold        `json:"thresholds,omitempty"`   // 判定结果
+	Summary    *jsonSummary           `json:"summary"`                // 整体结果
+	Endpoints  []*jsonSummary         `json:"endpoints,omitempty"`    // 分接口结果 只有一个接口时不输出
+	Intervals  []*statistics.Interval `json:"intervals"`              // 每个统计周期的数据
+}
+
+// jsonRequest 请求参数 敏感请求头、URL 中的密码已脱敏，body 只输出大小
+type jsonRequest struct {
+	Name      string            `json:"name"`              // 接口名称
+	URL       string            `json:"url"`               // URL
+	Protocol  string            `json:"protocol"`          // 协议
+	Method    string            `json:"method,omitempty"`  // 方法
+	Headers   map[string]string `json:"headers,omitempty"` // 请求头
+	BodyBytes int               `json:"body_bytes"`        // body 大小
+	Verify    string            `json:"verify"`            // 验证的方法
+	Code      int               `json:"code,omitempty"`    // 验证的状态码
+	Timeout   float64           `json:"timeout_ms"`        // 请求超时时间
+	HTTP2     bool              `json:"http2"`             // 是否使用http2.0
+	Keepalive bool              `json:"keepalive"`         // 是否开启长连接
+	MaxCon    int               `json:"max_con"`           // 每个连接的请求数
+}
+
+// jsonTask 压测任务参数
+type jsonTask struct {
+	Concurrency  uint64  `json:"concurrency"`              // 并发数
+	Number       uint64  `json:"number"`                   // 请求数(单个并发/协程) 0:不限制
+	Duration     float64 `json:"duration_ms"`              // 压测时长 0:不限制
+	Rate         float64 `json:"rate,omitempty"`           // 开放模型 每秒请求数
+	MaxInFlight  uint64  `json:"max_in_flight,omitempty"`  // 开放模型 最大并发请求数
+	Stages       string  `json:"stages,omitempty"`         // 分阶段压测
+	WarmUp       float64 `json:"warmup_ms,omitempty"`      // 预热时长
+	WarmUpNumber uint64  `json:"warmup_number,omitempty"`  // 预热请求数
+	CoCorrect    bool    `json:"co_correct,omitempty"`     // 是否输出协调遗漏修正后的耗时
+	CoInterval   float64 `json:"co_interval_ms,omitempty"` // 闭合模型 协调遗漏修正的期望请求间隔
+	Pacing       float64 `json:"pacing_ms,omitempty"`      // 闭合模型 节奏
+	ThinkTime    string  `json:"think_time,omitempty"`     // 闭合模型 思考时间
+}
+
+// jsonThreshold 判定结果
+type jsonThreshold struct {
+	Expr     string  `json:"expr"`               // 判定条件
+	Endpoint string  `json:"endpoint,omitempty"` // 接口名称
+	Actual   float64 `json:"actual"`             // 实际值
+	Passed   bool    `json:"passed"`             // 是否满足条件
+	Missing  bool    `json:"missing,omitempty"`  // 压测结果中没有该接口
+}
+
+// jsonSummary 压测结果
+type jsonSummary struct {
+	Name       string                  `json:"name,omitempty"`                 // 接口名称
+	Total      uint64                  `json:"total"`                          // 请求总数
+	SuccessNum uint64                  `json:"success"`                        // 成功数
+	FailureNum uint64                  `json:"failure"`                        // 失败数
+	Dropped    uint64                  `json:"dropped"`                        // 开放模型丢弃数
+	WarmUpNum  uint64                  `json:"warmup"`                         // 预热请求数 不计入统计
+	ErrorRate  float64                 `json:"error_rate"`                     // 错误率 百分比
+	Duration   float64                 `json:"duration_s"`                     // 压测时长 秒
+	Throughput jsonThroughput          `json:"throughput"`                     // 吞吐量
+	ErrCode    map[int]int             `json:"err_code"`                       // 错误码/错误个数
+	ErrNames   map[int]string          `json:"err_names,omitempty"`            // 错误码/错误分类名称
+	Errors     []jsonErrorSample       `json:"error_samples,omitempty"`        // 出现次数最多的错误信息
+	Latency    *jsonLatency            `json:"latency_ms"`                     // 耗时分布
+	Corrected  *jsonLatency            `json:"corrected_latency_ms,omitempty"` // 协调遗漏修正后的耗时分布
+	Phases     map[string]*jsonLatency `json:"phases_ms,omitempty"`            // HTTP 请求各阶段耗时分布
+	Outcomes   []*jsonBreakdown        `json:"outcomes,omitempty"`             // 按成功/失败分组的耗时和响应大小
+	Codes      []*jsonBreakdown        `json:"codes,omitempty"`                // 按错误码分组的耗时和响应大小
+}
+
+// jsonBreakdown 按错误码或成功/失败分组的耗时和响应大小
+type jsonBreakdown struct {
+	Name          string       `json:"name"`           // 分组名称
+	Code          int          `json:"code,omitempty"` // 错误码
+	Count         uint64       `json:"count"`          // 请求数
+	ReceivedBytes int64        `json:"received_bytes"` // 下载字节之和
+	AverageBytes  float64      `json:"avg_bytes"`      // 平均响应大小
+	MinBytes      int64        `json:"min_bytes"`      // 最小响应大小
+	MaxBytes      int64        `json:"max_bytes"`      // 最大响应大小
+	Latency       *jsonLatency `json:"latency_ms"`     // 耗时分布
+}
+
+// jsonErrorSample 错误信息
+type jsonErrorSample struct {
+	Message string `json:"message"` // 原始错误信息
+	Code    int    `json:"code"`    // 错误码
+	Count   uint64 `json:"count"`   // 出现次数
+}
+
+// jsonThroughput 吞吐量
+type jsonThroughput struct {
+	QPS                float64 `json:"qps"`                   // qps
+	ReceivedBytes      int64   `json:"received_bytes"`        // 下载字节
+	BytesPerSecond     float64 `json:"bytes_per_second"`      // 下载字节每秒
+	SentBytes          int64   `json:"sent_bytes"`            // 上传字节
+	SentBytesPerSecond float64 `json:"sent_bytes_per_second"` // 上传字节每秒
+}
+
+// jsonLatency 耗时分布
+type jsonLatency struct {
+	Min         float64            `json:"min"`               // 最短耗时
+	Max         float64            `json:"max"`               // 最长耗时
+	Avg         float64            `json:"avg"`               // 平均耗时
+	Percentiles map[string]float64 `json:"percentiles"`       // 耗时百分位 tp99:耗时
+	Buckets     []jsonBucket       `json:"buckets,omitempty"` // 直方图 次数不为0的区间，只有整体、分接口结果输出
+}
+
+// jsonBucket 直方图区间 [From, To]
+type jsonBucket struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count uint64  `json:"count"`
+}
+
+// WriteJSON 输出 JSON 格式压测报告
+func WriteJSON(path string, task *model.TaskForm, request *model.RequestForm, result *statistics.Result) error {
+	report := &jsonReport{
+		Time:      time.Now(),
+		Request:   newJSONRequest(request),
+		Task:      newJSONTask(task),
+		Passed:    result.Passed(),
+		Abort:     result.AbortReason,
+		Summary:   newJSONSummary(result),
+		Intervals: result.Intervals,
+	}
+	for _, item := range result.Thresholds {
+		report.Thresholds = append(report.Thresholds, jsonThreshold{
+			Expr:     item.Threshold.Expr,
+			Endpoint: item.Threshold.Endpoint,
+			Actual:   item.Actual,
+			Passed:   item.Passed,
+			Missing:  item.Missing,
+		})
+	}
+	for _, endpoint := range result.Endpoints {
+		report.Endpoints = append(report.Endpoints, newJSONSummary(endpoint))
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// newJSONRequest 请求参数
+func newJSONRequest(request *model.RequestForm) *jsonRequest {
+	redacted := redactRequest(request)
+	return &jsonRequest{
+		Name:      redacted.GetName(),
+		URL:       redacted.URL,
+		Protocol:  request.MP,
+		Method:    request.Method,
+		Headers:   redacted.Headers,
+		BodyBytes: len(request.Body),
+		Verify:    request.Verify,
+		Code:      request.Code,
+		Timeout:   milliseconds(request.ClientTimeout),
+		HTTP2:     request.HTTP2,
+		Keepalive: request.Keepalive,
+		MaxCon:    request.MaxCon,
+	}
+}
+
+// newJSONTask 压测任务参数
+func newJSONTask(task *model.TaskForm) *jsonTask {
+	t := &jsonTask{
+		Concurrency:  task.Concurrency,
+		Number:       task.Number,
+		Duration:     milliseconds(task.Duration),
+		Rate:         task.Rate,
+		WarmUp:       milliseconds(task.WarmUp),
+		WarmUpNumber: task.WarmUpNumber,
+		CoCorrect:    task.CoCorrect,
+		CoInterval:   milliseconds(task.CoInterval),
+		Pacing:       milliseconds(task.Pacing),
+	}
+	if task.IsOpen() {
+		t.MaxInFlight = task.MaxInFlight
+	}
+	if task.IsStaged() {
+		t.Stages = task.Stages.String()
+	}
+	if task.ThinkTime != nil {
+		t.ThinkTime = task.ThinkTime.String()
+	}
+	return t
+}
+
+// milliseconds 时长=>毫秒
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / 1e6
+}
+
+// newJSONSummary 压测结果
+func newJSONSummary(result *statistics.Result) *jsonSummary {
+	summary := &jsonSummary{
+		Name:       result.Name,
+		Total:      result.Total(),
+		SuccessNum: result.SuccessNum,
+		FailureNum: result.FailureNum,
+		Dropped:    result.Dropped,
+		WarmUpNum:  result.WarmUpNum,
+		ErrorRate:  result.ErrorRate(),
+		Duration:   float64(result.RequestTime) / 1e9,
+		Throughput: jsonThroughput{
+			QPS:           result.QPS,
+			ReceivedBytes: result.ReceivedBytes,
+			SentBytes:     result.SentBytes,
+		},
+		ErrCode: result.ErrCode,
+		Latency: newJSONLatency(result.Latency()),
+	}
+	summary.Latency.Buckets = newJSONBuckets(result.Latency())
+	if result.RequestTime > 0 {
+		summary.Throughput.BytesPerSecond = float64(result.ReceivedBytes) * 1e9 / float64(result.RequestTime)
+		summary.Throughput.SentBytesPerSecond = float64(result.SentBytes) * 1e9 / float64(result.RequestTime)
+	}
+	if result.Corrected() != nil {
+		summary.Corrected = newJSONLatency(result.Corrected())
+	}
+	for code := range result.ErrCode {
+		if name := model.ErrName(code); name != "" {
+			if summary.ErrNames == nil {
+				summary.ErrNames = make(map[int]string)
+			}
+			summary.ErrNames[code] = name
+		}
+	}
+	for _, sample := range result.ErrorSamples {
+		summary.Errors = append(summary.Errors, jsonErrorSample{
+			Message: sample.Message,
+			Code:    sample.Code,
+			Count:   sample.Count,
+		})
+	}
+	summary.Outcomes = newJSONBreakdowns(result.Outcomes)
+	summary.Codes = newJSONBreakdowns(result.Codes)
+	if len(result.Phases) > 0 {
+		summary.Phases = make(map[string]*jsonLatency)
+		for name, histogram := range result.Phases {
+			summary.Phases[name] = newJSONLatency(histogram)
+		}
+	}
+	return summary
+}
+
+// newJSONBreakdowns 分组的耗时和响应大小
+func newJSONBreakdowns(list []*statistics.Breakdown) (breakdowns []*jsonBreakdown) {
+	for _, breakdown := range list {
+		breakdowns = append(breakdowns, &jsonBreakdown{
+			Name:          breakdown.Name,
+			Code:          breakdown.Code,
+			Count:         breakdown.Count,
+			ReceivedBytes: breakdown.ReceivedBytes,
+			AverageBytes:  breakdown.AverageBytes(),
+			MinBytes:      breakdown.MinBytes,
+			MaxBytes:      breakdown.MaxBytes,
+			Latency:       newJSONLatency(breakdown.Latency()),
+		})
+	}
+	return
+}
+
+// newJSONLatency 耗时分布 纳秒=>毫秒，不包含直方图
+func newJSONLatency(histogram *statistics.Histogram) *jsonLatency {
+	latency := &jsonLatency{
+		Min:         float64(histogram.Min()) / 1e6,
+		Max:         float64(histogram.Max()) / 1e6,
+		Avg:         float64(histogram.Mean()) / 1e6,
+		Percentiles: make(map[string]float64),
+	}
+	percentiles := statistics.Percentiles()
+	for i, value := range histogram.Percentiles(percentiles) {
+		latency.Percentiles[statistics.PercentileName(percentiles[i])] = float64(value) / 1e6
+	}
+	return latency
+}
+
+// newJSONBuckets 直方图 子桶按 bucketDigits 位有效数字合并，区间起点为合并后的起点，
+// 两次压测的区间起点一致，对比(compare)时可以对齐
+func newJSONBuckets(histogram *statistics.Histogram) (buckets []jsonBucket) {
+	var last uint64
+	for _, bucket := range histogram.Buckets() {
+		from := truncateDigits(bucket.Lowest, bucketDigits)
+		if len(buckets) > 0 && from == last {
+			buckets[len(buckets)-1].To = float64(bucket.Highest) / 1e6
+			buckets[len(buckets)-1].Count = buckets[len(buckets)-1].Count + bucket.Count
+			continue
+		}
+		last = from
+		buckets = append(buckets, jsonBucket{
+			From:  float64(from) / 1e6,
+			To:    float64(bucket.Highest) / 1e6,
+			Count: bucket.Count,
+		})
+	}
+	return
+}
+
+// truncateDigits 保留 digits 位有效数字 其余位舍去 示例:12345,2=>12000
+func truncateDigits(value uint64, digits int) uint64 {
+	limit := uint64(math.Pow10(digits))
+	scale := uint64(1)
+	for value/scale >= limit {
+		scale = scale * 10
+	}
+	return value / scale * scale
+}
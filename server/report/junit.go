@@ -0,0 +1,114 @@
+// Package report 压测报告
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"goapistress/model"
+	"goapistress/server/statistics"
+)
+
+// junitTestSuite JUnit XML 测试套件
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase JUnit XML 测试用例 一个接口一个用例
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitFailure 用例失败信息
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit 输出 JUnit XML 格式压测报告 每个接口一个用例，有失败请求的接口用例失败
+// 设置了判定条件时每个条件一个用例，不满足时用例失败，提前结束压测时增加一个失败的用例
+func WriteJUnit(path string, request *model.RequestForm, result *statistics.Result) error {
+	suite := &junitTestSuite{
+		Name: "goapistress",
+		Time: fmt.Sprintf("%.3f", float64(result.RequestTime)/1e9),
+	}
+	for _, endpoint := range endpoints(request, result) {
+		testCase := junitTestCase{
+			Name:      endpoint.Name,
+			ClassName: "goapistress." + request.MP,
+			Time:      fmt.Sprintf("%.3f", float64(endpoint.RequestTime)/1e9),
+			SystemOut: fmt.Sprintf("total:%d success:%d failure:%d qps:%.2f avg:%.3fms tp99:%.3fms",
+				endpoint.Total(), endpoint.SuccessNum, endpoint.FailureNum, endpoint.QPS,
+				float64(endpoint.AverageTime())/1e6, float64(endpoint.Percentile(99))/1e6),
+		}
+		if endpoint.FailureNum > 0 {
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d/%d 请求失败 错误率:%.2f%%", endpoint.FailureNum, endpoint.Total(),
+					endpoint.ErrorRate()),
+				Type: "RequestFailure",
+				Text: "错误码:" + errCodeString(endpoint.ErrCode),
+			}
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	for _, item := range result.Thresholds {
+		testCase := junitTestCase{
+			Name:      item.Threshold.Expr,
+			ClassName: "goapistress.thresholds",
+			Time:      suite.Time,
+			SystemOut: fmt.Sprintf("actual:%.3f", item.Actual),
+		}
+		if !item.Passed {
+			message := fmt.Sprintf("判定条件不满足 实际值:%.3f", item.Actual)
+			if item.Missing {
+				message = "压测结果中没有该接口:" + item.Threshold.Endpoint
+			}
+			testCase.Failure = &junitFailure{Message: message, Type: "ThresholdFailure"}
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	if result.AbortReason != "" {
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			Name:      "abort",
+			ClassName: "goapistress.abort",
+			Time:      suite.Time,
+			Failure:   &junitFailure{Message: "提前结束:" + result.AbortReason, Type: "Aborted"},
+		})
+		suite.Failures++
+	}
+	suite.Tests = len(suite.TestCases)
+	data, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), data...), 0644)
+}
+
+// errCodeString 错误码:次数 按错误码排序
+func errCodeString(errCode map[int]int) string {
+	codes := make([]int, 0, len(errCode))
+	for code := range errCode {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	arr := make([]string, len(codes))
+	for i, code := range codes {
+		arr[i] = fmt.Sprintf("%s:%d", model.ErrCodeString(code), errCode[code])
+	}
+	return strings.Join(arr, ";")
+}
@@ -0,0 +1,44 @@
+// Package report 压测报告
+package report
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goapistress/model"
+	"goapistress/server/statistics"
+)
+
+// TestWriteJUnit 测试每个接口一个用例，有失败请求时用例失败
+func TestWriteJUnit(t *testing.T) {
+	request := &model.RequestForm{URL: "http://127.0.0.1/", MP: model.MPTypeHTTP, Method: "GET"}
+	result := &statistics.Result{
+		SuccessNum: 9,
+		FailureNum: 1,
+		ErrCode:    map[int]int{200: 9, 500: 1},
+		Endpoints: []*statistics.Result{
+			{Name: "a", SuccessNum: 5, ErrCode: map[int]int{200: 5}},
+			{Name: "b", SuccessNum: 4, FailureNum: 1, ErrCode: map[int]int{200: 4, 500: 1}},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	if err := WriteJUnit(path, request, result); err != nil {
+		t.Fatalf("输出失败:%v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取失败:%v", err)
+	}
+	suite := &junitTestSuite{}
+	if err = xml.Unmarshal(data, suite); err != nil {
+		t.Fatalf("解析失败:%v", err)
+	}
+	if suite.Tests != 2 || suite.Failures != 1 {
+		t.Errorf("数据不一致 预期:2,1 实际:%d,%d", suite.Tests, suite.Failures)
+	}
+	if suite.TestCases[0].Failure != nil || suite.TestCases[1].Failure == nil {
+		t.Errorf("数据不一致 实际:%+v", suite.TestCases)
+	}
+}
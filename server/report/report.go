@@ -0,0 +1,53 @@
+// Package report 压测报告 将压测结果输出为 JSON、JUnit XML 等机器可读的格式
+package report
+
+import (
+	"net/url"
+	"strings"
+
+	"goapistress/model"
+	"goapistress/server/statistics"
+)
+
+// endpoints 需要单独输出的接口，只压测一个接口时为整体结果
+func endpoints(request *model.RequestForm, result *statistics.Result) []*statistics.Result {
+	if len(result.Endpoints) > 0 {
+		return result.Endpoints
+	}
+	total := *result
+	total.Name = request.GetName()
+	return []*statistics.Result{&total}
+}
+
+// sensitiveHeaders 需要脱敏的请求头 名称包含这些字符串时(不区分大小写)只输出 ***
+var sensitiveHeaders = []string{"authorization", "cookie", "token", "secret", "password", "api-key", "apikey",
+	"session", "signature"}
+
+// redactRequest 脱敏后的请求参数副本 隐藏敏感请求头的值和 URL 中的密码，报告文件可能会被分享
+func redactRequest(request *model.RequestForm) *model.RequestForm {
+	redacted := *request
+	if u, err := url.Parse(request.URL); err == nil && u.User != nil {
+		redacted.URL = u.Redacted()
+	}
+	if len(request.Headers) > 0 {
+		redacted.Headers = make(map[string]string, len(request.Headers))
+		for key, value := range request.Headers {
+			if isSensitiveHeader(key) {
+				value = "***"
+			}
+			redacted.Headers[key] = value
+		}
+	}
+	return &redacted
+}
+
+// isSensitiveHeader 是否为需要脱敏的请求头
+func isSensitiveHeader(key string) bool {
+	key = strings.ToLower(key)
+	for _, name := range sensitiveHeaders {
+		if strings.Contains(key, name) {
+			return true
+		}
+	}
+	return false
+}
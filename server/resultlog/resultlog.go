@@ -0,0 +1,206 @@
+// Package resultlog 请求结果日志 逐条记录压测的请求结果，用于压测结束后的离线分析
+package resultlog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"goapistress/model"
+)
+
+const (
+	bufferSize = 64 * 1024 // 文件写缓冲 字节
+	queueSize  = 8192      // 等待写入的请求结果数 写入跟不上时丢弃成功的请求
+)
+
+// record JSON Lines 的一行 时间为毫秒
+type record struct {
+	ID            string  `json:"id"`
+	ChanID        uint64  `json:"chan_id"`
+	Time          string  `json:"time"`       // 请求发起时间 RFC3339Nano
+	Latency       float64 `json:"latency_ms"` // 耗时
+	Success       bool    `json:"success"`
+	Code          int     `json:"code"`
+	ReceivedBytes int64   `json:"received_bytes"`
+	SentBytes     int64   `json:"sent_bytes"`
+	Endpoint      string  `json:"endpoint"`
+	Error         string  `json:"error,omitempty"` // 请求失败时的原始错误信息
+	WarmUp        bool    `json:"warm_up"`         // 是否为预热阶段的请求 不计入统计
+}
+
+// csvTitles CSV 表头 与 record 字段一一对应
+var csvTitles = []string{"id", "chan_id", "time", "latency_ms", "success", "code", "received_bytes", "sent_bytes",
+	"endpoint", "error", "warm_up"}
+
+// Writer 请求结果日志 实现 server.Observer，在单独的协程中经缓冲写入文件，成功的请求不阻塞接收结果
+// 容量搜索时每一级压测都写入同一个文件
+type Writer struct {
+	file    *os.File
+	buf     *bufio.Writer
+	csv     *csv.Writer                // CSV 格式
+	json    *json.Encoder              // JSON Lines 格式
+	sample  float64                    // 成功请求的采样比例 0~1
+	random  *rand.Rand                 // 采样 只在接收结果的协程中使用
+	queue   chan *model.RequestResults // 等待写入的请求结果
+	done    chan error                 // 写入协程退出 返回写入错误
+	dropped uint64                     // 写入跟不上丢弃的成功请求条数
+	written uint64                     // 写入的条数
+}
+
+// Open 打开请求结果日志文件 扩展名为 .csv 时写 CSV，.jsonl .json .ndjson 写 JSON Lines
+// sample 成功请求的采样比例 0~1，1 为全部记录，失败的请求全部记录
+func Open(path string, sample float64) (w *Writer, err error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".csv" && ext != ".jsonl" && ext != ".json" && ext != ".ndjson" {
+		return nil, fmt.Errorf("请求结果日志格式不支持:%s 支持:.csv .jsonl", path)
+	}
+	if sample <= 0 || sample > 1 {
+		return nil, fmt.Errorf("请求结果日志采样比例不合法:%v 取值 0~1", sample)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w = &Writer{
+		file:   file,
+		buf:    bufio.NewWriterSize(file, bufferSize),
+		sample: sample,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if ext == ".csv" {
+		w.csv = csv.NewWriter(w.buf)
+		if err = w.csv.Write(csvTitles); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	} else {
+		w.json = json.NewEncoder(w.buf)
+	}
+	return w, nil
+}
+
+// Start 压测开始 启动写入协程
+func (w *Writer) Start(task *model.TaskForm, request *model.RequestForm, status *model.TaskStatus) {
+	w.queue = make(chan *model.RequestResults, queueSize)
+	w.done = make(chan error, 1)
+	go w.run(w.queue, w.done)
+}
+
+// Observe 采样后放入写入队列 队列满时丢弃成功的请求，不等待写入；失败的请求全部记录，队列满时等待写入
+func (w *Writer) Observe(data *model.RequestResults) {
+	if !data.IsSucceed {
+		w.queue <- data
+		return
+	}
+	if w.sample < 1 && w.random.Float64() >= w.sample {
+		return
+	}
+	select {
+	case w.queue <- data:
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+	}
+}
+
+// Stop 压测结束 等待队列中的请求结果写完并刷新缓冲
+func (w *Writer) Stop() {
+	close(w.queue)
+	if err := <-w.done; err != nil {
+		fmt.Println("请求结果日志写入失败:", err)
+	}
+}
+
+// Close 关闭文件 输出写入、丢弃的条数
+func (w *Writer) Close() error {
+	fmt.Printf("请求结果日志:%s 写入:%d 条", w.file.Name(), atomic.LoadUint64(&w.written))
+	if dropped := atomic.LoadUint64(&w.dropped); dropped > 0 {
+		fmt.Printf(" 写入跟不上丢弃成功的请求:%d 条，可以设置采样比例", dropped)
+	}
+	fmt.Println()
+	return w.file.Close()
+}
+
+// run 写入协程 写入出错以后只消费队列，不再写入
+func (w *Writer) run(queue <-chan *model.RequestResults, done chan<- error) {
+	var err error
+	for data := range queue {
+		if err == nil {
+			err = w.write(data)
+		}
+	}
+	if err == nil {
+		err = w.flush()
+	}
+	done <- err
+}
+
+// write 写入一个请求结果 分步压测时每一步写一行，ID、是否预热与整个请求相同
+func (w *Writer) write(data *model.RequestResults) error {
+	if len(data.Steps) == 0 {
+		return w.writeRecord(data, data)
+	}
+	for _, step := range data.Steps {
+		if err := w.writeRecord(data, step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRecord 写入一行 request 为整个请求，data 为请求或其中的一步
+func (w *Writer) writeRecord(request, data *model.RequestResults) (err error) {
+	r := &record{
+		ID:            request.ID,
+		ChanID:        request.ChanID,
+		Time:          data.StartTime.Format(time.RFC3339Nano),
+		Latency:       float64(data.Time) / 1e6,
+		Success:       data.IsSucceed,
+		Code:          data.ErrCode,
+		ReceivedBytes: data.ReceivedBytes,
+		SentBytes:     data.SentBytes,
+		Endpoint:      data.Endpoint,
+		Error:         data.ErrMsg,
+		WarmUp:        request.WarmUp,
+	}
+	if w.json != nil {
+		err = w.json.Encode(r)
+	} else {
+		err = w.csv.Write([]string{
+			r.ID,
+			strconv.FormatUint(r.ChanID, 10),
+			r.Time,
+			strconv.FormatFloat(r.Latency, 'f', 3, 64),
+			strconv.FormatBool(r.Success),
+			strconv.Itoa(r.Code),
+			strconv.FormatInt(r.ReceivedBytes, 10),
+			strconv.FormatInt(r.SentBytes, 10),
+			r.Endpoint,
+			r.Error,
+			strconv.FormatBool(r.WarmUp),
+		})
+	}
+	if err == nil {
+		atomic.AddUint64(&w.written, 1)
+	}
+	return
+}
+
+// flush 刷新缓冲
+func (w *Writer) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.buf.Flush()
+}
@@ -0,0 +1,122 @@
+// Package resultlog 请求结果日志
+package resultlog
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"goapistress/model"
+)
+
+// TestWriter 测试 JSON Lines、CSV 格式和采样 采样比例很小时只记录失败的请求
+func TestWriter(t *testing.T) {
+	start := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	results := []*model.RequestResults{
+		{ID: "0_0", ChanID: 0, StartTime: start, Time: 1500000, IsSucceed: true, ErrCode: 200, ReceivedBytes: 10,
+			SentBytes: 20, Endpoint: "login"},
+		{ID: "1_0", ChanID: 1, StartTime: start, Time: 2000000, ErrCode: 602, ErrMsg: "connection refused",
+			Endpoint: "login"},
+		{ID: "1_1", ChanID: 1, StartTime: start, Time: 3000000, IsSucceed: true, ErrCode: 200, WarmUp: true, Steps: []*model.RequestResults{
+			{StartTime: start, Time: 1000000, IsSucceed: true, ErrCode: 200, Endpoint: "step1"},
+			{StartTime: start, Time: 2000000, IsSucceed: true, ErrCode: 200, Endpoint: "step2"},
+		}},
+	}
+	tt := map[string]struct {
+		file   string
+		sample float64
+		lines  []string
+	}{
+		"jsonl": {file: "results.jsonl", sample: 1, lines: []string{
+			`{"id":"0_0","chan_id":0,"time":"2022-01-02T03:04:05Z","latency_ms":1.5,"success":true,"code":200,"received_bytes":10,"sent_bytes":20,"endpoint":"login","warm_up":false}`,
+			`{"id":"1_0","chan_id":1,"time":"2022-01-02T03:04:05Z","latency_ms":2,"success":false,"code":602,"received_bytes":0,"sent_bytes":0,"endpoint":"login","error":"connection refused","warm_up":false}`,
+			`{"id":"1_1","chan_id":1,"time":"2022-01-02T03:04:05Z","latency_ms":1,"success":true,"code":200,"received_bytes":0,"sent_bytes":0,"endpoint":"step1","warm_up":true}`,
+			`{"id":"1_1","chan_id":1,"time":"2022-01-02T03:04:05Z","latency_ms":2,"success":true,"code":200,"received_bytes":0,"sent_bytes":0,"endpoint":"step2","warm_up":true}`,
+		}},
+		"csv": {file: "results.csv", sample: 1, lines: []string{
+			"id,chan_id,time,latency_ms,success,code,received_bytes,sent_bytes,endpoint,error,warm_up",
+			"0_0,0,2022-01-02T03:04:05Z,1.500,true,200,10,20,login,,false",
+			"1_0,1,2022-01-02T03:04:05Z,2.000,false,602,0,0,login,connection refused,false",
+			"1_1,1,2022-01-02T03:04:05Z,1.000,true,200,0,0,step1,,true",
+			"1_1,1,2022-01-02T03:04:05Z,2.000,true,200,0,0,step2,,true",
+		}},
+		"sample": {file: "sample.csv", sample: 1e-9, lines: []string{
+			"id,chan_id,time,latency_ms,success,code,received_bytes,sent_bytes,endpoint,error,warm_up",
+			"1_0,1,2022-01-02T03:04:05Z,2.000,false,602,0,0,login,connection refused,false",
+		}},
+	}
+	for name, value := range tt {
+		path := filepath.Join(t.TempDir(), value.file)
+		w, err := Open(path, value.sample)
+		if err != nil {
+			t.Fatalf("%s 打开失败:%v", name, err)
+		}
+		w.Start(nil, nil, nil)
+		for _, data := range results {
+			w.Observe(data)
+		}
+		w.Stop()
+		if err = w.Close(); err != nil {
+			t.Fatalf("%s 关闭失败:%v", name, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.Join(value.lines, "\n")
+		if got := strings.TrimSpace(string(data)); got != want {
+			t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, want, got)
+		}
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "results.txt"), 1); err == nil {
+		t.Errorf("数据不一致 预期:格式不支持 实际:%v", err)
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "results.csv"), 0); err == nil {
+		t.Errorf("数据不一致 预期:采样比例不合法 实际:%v", err)
+	}
+}
+
+// TestWriterQueueFull 测试队列满时只丢弃成功的请求 失败的请求等待写入
+func TestWriterQueueFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	w, err := Open(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 队列只能放两条 写入协程稍后启动
+	w.queue = make(chan *model.RequestResults, 2)
+	w.done = make(chan error, 1)
+	for i := 0; i < 3; i++ {
+		w.Observe(&model.RequestResults{ID: "0_" + strconv.Itoa(i), IsSucceed: true, ErrCode: 200})
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		w.run(w.queue, w.done)
+	}()
+	w.Observe(&model.RequestResults{ID: "1_0", ErrCode: 602, ErrMsg: "connection refused"})
+	w.Stop()
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if dropped := atomic.LoadUint64(&w.dropped); dropped != 1 {
+		t.Errorf("丢弃条数 数据不一致 预期:%v 实际:%v", 1, dropped)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{"0_0", "0_1", "1_0"}
+	if len(lines) != len(want)+1 {
+		t.Fatalf("行数 数据不一致 预期:%v 实际:%v", len(want)+1, len(lines))
+	}
+	for i, id := range want {
+		if !strings.HasPrefix(lines[i+1], id+",") {
+			t.Errorf("第%d行 数据不一致 预期:%v 实际:%v", i+1, id, lines[i+1])
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package server 压测启动
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"goapistress/model"
+	"goapistress/server/statistics"
+)
+
+// searchStep 容量搜索 每一级的压测结果
+type searchStep struct {
+	level  float64            // 负载
+	result *statistics.Result // 压测结果
+	failed []string           // 不满足的判定条件
+}
+
+// Search 容量搜索 逐级增加负载(闭合模型为并发数，开放模型为每秒请求数)并压测，
+// 某一级不满足判定条件时停止，输出最后一个满足条件的负载，返回是否有满足条件的负载
+func Search(ctx context.Context, task *model.TaskForm, search *model.SearchForm,
+	request *model.RequestForm) (passed bool, err error) {
+	thresholds, err := statistics.ParseThresholds(search.Thresholds)
+	if err != nil {
+		return
+	}
+	if len(thresholds) == 0 {
+		return false, fmt.Errorf("容量搜索判定条件不能为空")
+	}
+	var steps []*searchStep
+	for _, level := range search.Levels() {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Printf("\n 容量搜索 第%d级 负载:%v 压测时长:%s \n", len(steps)+1, level, search.StepDuration)
+		step := &searchStep{
+			level:  level,
+			result: Dispose(ctx, search.StepTask(task, level), request),
+		}
+		for _, threshold := range thresholds {
+			if actual, ok := threshold.Check(step.result); !ok {
+				step.failed = append(step.failed, fmt.Sprintf("%s(实际:%.2f)", threshold.Expr, actual))
+			}
+		}
+		// 没有完成的请求时耗时、错误率都为0，会满足所有判定条件，视为不通过
+		if step.result.SuccessNum+step.result.FailureNum == 0 {
+			step.failed = append(step.failed, "没有完成的请求")
+		}
+		// 满足提前结束条件的一级视为不通过
+		if step.result.AbortReason != "" {
+			step.failed = append(step.failed, "提前结束:"+step.result.AbortReason)
+		}
+		steps = append(steps, step)
+		if len(step.failed) > 0 {
+			break
+		}
+	}
+	passed = printSearch(task, thresholds, steps)
+	return
+}
+
+// printSearch 打印容量搜索结果 返回是否有满足条件的负载
+func printSearch(task *model.TaskForm, thresholds []*statistics.Threshold, steps []*searchStep) bool {
+	unit := "并发数"
+	if task.IsOpen() {
+		unit = "每秒请求数"
+	}
+	var exprs []string
+	for _, threshold := range thresholds {
+		exprs = append(exprs, threshold.Expr)
+	}
+	fmt.Printf("\n\n")
+	fmt.Println("*************************  容量搜索 stat  ****************************")
+	fmt.Println("判定条件:", strings.Join(exprs, ","))
+	fmt.Println("─────┬────────┬────────┬────────┬────────┬────────┬────────")
+	fmt.Println(" 级数│    负载│   qps  │  错误率│平均耗时│  tp99  │ 结果")
+	fmt.Println("─────┼────────┼────────┼────────┼────────┼────────┼────────")
+	var sustainable *searchStep
+	for i, step := range steps {
+		conclusion := "通过"
+		if len(step.failed) > 0 {
+			conclusion = "不通过 " + strings.Join(step.failed, ";")
+		} else {
+			sustainable = step
+		}
+		fmt.Printf("%5d│%8.1f│%8.2f│%7.2f%%│%8.2f│%8.2f│%s\n", i+1, step.level, step.result.QPS,
+			step.result.ErrorRate(), float64(step.result.AverageTime())/1e6,
+			float64(step.result.Percentile(99))/1e6, conclusion)
+	}
+	if sustainable == nil {
+		fmt.Println("最大可持续负载: 无，起始负载已不满足判定条件")
+	} else {
+		fmt.Printf("最大可持续负载: %s %v qps:%.2f \n", unit, sustainable.level, sustainable.result.QPS)
+	}
+	fmt.Println("*************************  容量搜索 end   ****************************")
+	fmt.Printf("\n\n")
+	return sustainable != nil
+}
@@ -0,0 +1,84 @@
+// Package server 压测启动
+package server
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"goapistress/model"
+)
+
+const (
+	stageInterval = 100 * time.Millisecond // 分阶段压测 更新目标值的时间间隔
+)
+
+// stageWorker 分阶段压测中运行的协程
+type stageWorker struct {
+	chanID uint64             // 协程编号
+	cancel context.CancelFunc // 停止协程
+}
+
+// runStages 分阶段压测 按阶段更新目标值，直到 ctx 结束
+// start 不为空时(闭合模型)按目标值启动、停止协程，后启动的协程先停止；
+// 协程退出时调用 done，完成请求数(-n)退出的协程不再计入并发数，也不再重新启动
+func runStages(ctx context.Context, task *model.TaskForm, status *model.TaskStatus,
+	start func(workerCtx context.Context, chanID uint64, done func())) {
+	var (
+		startTime = time.Now()
+		mutex     sync.Mutex
+		running   []stageWorker // 运行中的协程
+		finished  int           // 完成请求数退出的协程数
+		chanID    uint64        // 下一个协程编号
+	)
+	// done 协程退出 被停止的协程已经不在 running 中
+	done := func(id uint64) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for i, worker := range running {
+			if worker.chanID == id {
+				running = append(running[:i], running[i+1:]...)
+				finished++
+				status.SetWorkers(len(running))
+				return
+			}
+		}
+	}
+	ticker := time.NewTicker(stageInterval)
+	defer ticker.Stop()
+	for {
+		target, stage := task.Target(time.Since(startTime))
+		status.SetStage(stage, target)
+		if start != nil {
+			workers := int(math.Round(target))
+			mutex.Lock()
+			for len(running)+finished < workers {
+				workerCtx, cancel := context.WithCancel(ctx)
+				id := chanID
+				running = append(running, stageWorker{chanID: id, cancel: cancel})
+				start(workerCtx, id, func() {
+					done(id)
+				})
+				chanID++
+			}
+			for len(running) > 0 && len(running)+finished > workers {
+				running[len(running)-1].cancel()
+				running = running[:len(running)-1]
+			}
+			status.SetWorkers(len(running))
+			mutex.Unlock()
+		}
+		select {
+		case <-ctx.Done():
+			mutex.Lock()
+			for _, worker := range running {
+				worker.cancel()
+			}
+			running = nil
+			mutex.Unlock()
+			return
+		case <-ticker.C:
+		}
+	}
+}
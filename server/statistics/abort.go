@@ -0,0 +1,101 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AbortCondition 提前结束压测的条件 按每个统计周期的数据判定，连续满足 For 时长以后结束压测
+// 示例: error_rate>50%:10s 连续10秒错误率大于50%，p95>5s:30s 连续30秒 p95 大于5秒
+type AbortCondition struct {
+	Threshold *Threshold    // 条件 满足时计时
+	For       time.Duration // 连续满足的时长 0:满足一个统计周期即结束
+}
+
+// ParseAbortConditions 解析提前结束压测的条件，多个条件以逗号分隔
+func ParseAbortConditions(str string) (conditions []*AbortCondition, err error) {
+	for _, expr := range strings.Split(str, ",") {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+		// 格式为 条件 或 条件:持续时长，条件和时长中都不能有冒号
+		condition := &AbortCondition{}
+		fields := strings.Split(expr, ":")
+		if len(fields) > 2 || strings.TrimSpace(fields[0]) == "" {
+			return nil, fmt.Errorf("提前结束条件格式不合法:%s 示例:error_rate>50%%:10s", expr)
+		}
+		if len(fields) == 2 {
+			if condition.For, err = time.ParseDuration(strings.TrimSpace(fields[1])); err != nil || condition.For < 0 {
+				return nil, fmt.Errorf("提前结束条件的持续时长不合法:%s 示例:error_rate>50%%:10s", expr)
+			}
+		}
+		if condition.Threshold, err = parseThreshold(strings.TrimSpace(fields[0])); err != nil {
+			return nil, err
+		}
+		if condition.Threshold.Endpoint != "" {
+			return nil, fmt.Errorf("提前结束条件不支持按接口判定:%s", expr)
+		}
+		conditions = append(conditions, condition)
+	}
+	return
+}
+
+// String 条件 示例: error_rate>50% 持续10s
+func (c *AbortCondition) String() string {
+	if c.For == 0 {
+		return c.Threshold.Expr
+	}
+	return c.Threshold.Expr + " 持续" + c.For.String()
+}
+
+// abortConditions 提前结束压测的条件
+var abortConditions []*AbortCondition
+
+// SetAbortConditions 设置提前结束压测的条件 多个条件以逗号分隔
+func SetAbortConditions(str string) (err error) {
+	list, err := ParseAbortConditions(str)
+	if err != nil {
+		return
+	}
+	abortConditions = list
+	return
+}
+
+// abortCheck 按统计周期判定提前结束条件
+type abortCheck struct {
+	conditions []*AbortCondition
+	held       []int // 各条件连续满足的统计周期数
+}
+
+// newAbortCheck 按统计周期判定提前结束条件
+func newAbortCheck() *abortCheck {
+	return &abortCheck{
+		conditions: abortConditions,
+		held:       make([]int, len(abortConditions)),
+	}
+}
+
+// check 判定一个统计周期 返回提前结束的原因，不需要结束时为空
+// 没有完成请求的统计周期(例如请求全部超时还没返回)没有耗时和错误率，跳过，不计数也不重新计时
+func (a *abortCheck) check(result *Result) (reason string) {
+	if result.SuccessNum+result.FailureNum == 0 {
+		return
+	}
+	for i, condition := range a.conditions {
+		actual, ok := condition.Threshold.Check(result)
+		if !ok {
+			a.held[i] = 0
+			continue
+		}
+		a.held[i]++
+		// 持续时长换算为统计周期数，不足一个周期按一个周期
+		need := int((condition.For + exportStatisticsTime - 1) / exportStatisticsTime)
+		if a.held[i] >= need && reason == "" {
+			reason = fmt.Sprintf("%s(实际:%.3f)", condition, actual)
+		}
+	}
+	return
+}
@@ -0,0 +1,120 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"fmt"
+	"sort"
+
+	"goapistress/model"
+)
+
+// 按成功/失败分组的名称
+const (
+	outcomeSuccess = "success" // 成功
+	outcomeFailure = "failure" // 失败
+)
+
+// Breakdown 按错误码或成功/失败分组的耗时和响应大小 时间都是纳秒
+type Breakdown struct {
+	Name          string     // 分组名称 错误码为 ErrCodeString，成功/失败为 success failure
+	Code          int        // 错误码 按成功/失败分组时为0
+	Count         uint64     // 请求数
+	ReceivedBytes int64      // 下载字节之和
+	MinBytes      int64      // 最小响应大小
+	MaxBytes      int64      // 最大响应大小
+	latency       *Histogram // 耗时分布
+}
+
+// newBreakdown 分组统计
+func newBreakdown(name string, code int) *Breakdown {
+	return &Breakdown{
+		Name:    name,
+		Code:    code,
+		latency: NewHistogram(),
+	}
+}
+
+// add 记录一个请求结果
+func (b *Breakdown) add(data *model.RequestResults) {
+	if b.Count == 0 || b.MinBytes > data.ReceivedBytes {
+		b.MinBytes = data.ReceivedBytes
+	}
+	if b.MaxBytes < data.ReceivedBytes {
+		b.MaxBytes = data.ReceivedBytes
+	}
+	b.Count++
+	b.ReceivedBytes = b.ReceivedBytes + data.ReceivedBytes
+	b.latency.Record(data.Time)
+}
+
+// Latency 耗时分布
+func (b *Breakdown) Latency() *Histogram {
+	return b.latency
+}
+
+// AverageBytes 平均响应大小
+func (b *Breakdown) AverageBytes() float64 {
+	if b.Count == 0 {
+		return 0
+	}
+	return float64(b.ReceivedBytes) / float64(b.Count)
+}
+
+// codes 按错误码、成功/失败分组统计 与错误码统计一致，分步压测时按整个请求记录
+type codes struct {
+	list     map[int]*Breakdown // 错误码/统计
+	outcomes [2]*Breakdown      // 成功、失败
+}
+
+// newCodes 按错误码、成功/失败分组统计
+func newCodes() *codes {
+	return &codes{
+		list:     make(map[int]*Breakdown),
+		outcomes: [2]*Breakdown{newBreakdown(outcomeSuccess, 0), newBreakdown(outcomeFailure, 0)},
+	}
+}
+
+// add 记录一个请求结果
+func (c *codes) add(data *model.RequestResults) {
+	breakdown, ok := c.list[data.ErrCode]
+	if !ok {
+		breakdown = newBreakdown(model.ErrCodeString(data.ErrCode), data.ErrCode)
+		c.list[data.ErrCode] = breakdown
+	}
+	breakdown.add(data)
+	if data.IsSucceed {
+		c.outcomes[0].add(data)
+	} else {
+		c.outcomes[1].add(data)
+	}
+}
+
+// results 按错误码从小到大的统计、成功/失败的统计 没有请求的分组不返回
+func (c *codes) results() (list []*Breakdown, outcomes []*Breakdown) {
+	for _, breakdown := range c.list {
+		list = append(list, breakdown)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Code < list[j].Code
+	})
+	for _, breakdown := range c.outcomes {
+		if breakdown.Count > 0 {
+			outcomes = append(outcomes, breakdown)
+		}
+	}
+	return
+}
+
+// printCodes 输出按成功/失败、错误码分组的耗时和响应大小 时长都为毫秒，大小为字节
+func printCodes(result *Result) {
+	if len(result.Codes) <= 1 {
+		return
+	}
+	fmt.Println("按成功/失败、错误码统计:")
+	fmt.Println("     次数│平均耗时│最长耗时│" + percentileTitles() + "│  平均大小│  最大大小│ 分组")
+	for _, breakdown := range append(result.Outcomes, result.Codes...) {
+		fmt.Printf("%9d│%8.2f│%8.2f│%s│%10.0f│%10d│ %s\n", breakdown.Count,
+			float64(breakdown.latency.Mean())/1e6, float64(breakdown.latency.Max())/1e6,
+			percentileValues(breakdown.latency), breakdown.AverageBytes(), breakdown.MaxBytes, breakdown.Name)
+	}
+}
@@ -0,0 +1,20 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"goapistress/model"
+)
+
+// correctedTimes 协调遗漏修正后的耗时，逐个交给 record
+// 开放模型(有计划发起时间) 耗时从计划发起时间开始计算
+// 闭合模型 耗时超过期望请求间隔 interval 时，按 HdrHistogram 的方式补齐期间被阻塞而没有发出的请求
+func correctedTimes(data *model.RequestResults, interval uint64, record func(requestTime uint64)) {
+	corrected := data.Time + data.Delay
+	record(corrected)
+	if interval == 0 || corrected <= interval {
+		return
+	}
+	for missing := corrected - interval; missing >= interval; missing = missing - interval {
+		record(missing)
+	}
+}
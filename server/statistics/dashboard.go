@@ -0,0 +1,296 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"goapistress/model"
+	"goapistress/tools"
+)
+
+// 终端控制序列
+const (
+	ansiEnter = "\x1b[?1049h\x1b[?25l" // 进入备用屏幕 隐藏光标
+	ansiLeave = "\x1b[?25h\x1b[?1049l" // 显示光标 回到主屏幕
+	ansiClear = "\x1b[H\x1b[2J"        // 光标移到左上角 清屏
+)
+
+var (
+	activeMutex sync.Mutex
+	active      *dashboardView // 当前进入备用屏幕的全屏面板 强制退出时恢复终端
+)
+
+// sparkBlocks 迷你折线图的字符 从低到高
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// dashboardView 全屏面板 每秒重绘，显示吞吐量和耗时的变化、当前百分位、错误码、并发数和进度
+type dashboardView struct {
+	mutex   sync.Mutex
+	task    *model.TaskForm
+	width   int         // 终端宽度
+	height  int         // 终端高度
+	started bool        // 是否已进入备用屏幕
+	qps     []float64   // 每个统计周期的 qps
+	latency []float64   // 每个统计周期的最高百分位耗时 毫秒
+	errCode map[int]int // 累计的错误码/错误个数
+	last    *snapshot   // 最近一次的累计数据
+}
+
+// newDashboardView 全屏面板
+func newDashboardView(task *model.TaskForm, width, height int) *dashboardView {
+	return &dashboardView{
+		task:    task,
+		width:   width,
+		height:  height,
+		errCode: make(map[int]int),
+	}
+}
+
+// header 进入备用屏幕
+func (d *dashboardView) header() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if !d.started {
+		d.started = true
+		fmt.Print(ansiEnter)
+		// 备用屏幕上不输出压测过程中的日志
+		tools.SetQuiet(true)
+		activeMutex.Lock()
+		active = d
+		activeMutex.Unlock()
+	}
+}
+
+// update 记录累计数据并重绘
+func (d *dashboardView) update(snap *snapshot) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	// 忽略比已显示的更旧的数据
+	if d.last != nil && d.last.requestTime > snap.requestTime {
+		return
+	}
+	d.last = snap
+	if !d.started {
+		return
+	}
+	if width, height, ok := terminalSize(); ok {
+		d.width, d.height = width, height
+	}
+	var b strings.Builder
+	b.WriteString(ansiClear)
+	for i, line := range d.lines() {
+		if i >= d.height-1 {
+			break
+		}
+		b.WriteString(truncate(line, d.width))
+		b.WriteString("\r\n")
+	}
+	_, _ = os.Stdout.WriteString(b.String())
+}
+
+// interval 记录统计周期的数据
+func (d *dashboardView) interval(interval *Interval) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.qps = append(d.qps, interval.QPS)
+	var value float64
+	if len(percentiles) > 0 {
+		value = interval.Percentiles[PercentileName(percentiles[len(percentiles)-1])]
+	}
+	d.latency = append(d.latency, value)
+	for code, count := range interval.ErrCode {
+		d.errCode[code] = d.errCode[code] + count
+	}
+}
+
+// close 回到主屏幕，在主屏幕保留最后一次的面板内容
+func (d *dashboardView) close() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if !d.started {
+		return
+	}
+	d.started = false
+	fmt.Print(ansiLeave)
+	tools.SetQuiet(false)
+	activeMutex.Lock()
+	active = nil
+	activeMutex.Unlock()
+	fmt.Println()
+	for _, line := range d.lines() {
+		fmt.Println(truncate(line, d.width))
+	}
+}
+
+// RestoreTerminal 全屏面板没有关闭时回到主屏幕并显示光标 强制退出前调用
+func RestoreTerminal() {
+	activeMutex.Lock()
+	d := active
+	active = nil
+	activeMutex.Unlock()
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.started {
+		d.started = false
+		fmt.Print(ansiLeave)
+		tools.SetQuiet(false)
+	}
+}
+
+// lines 面板内容 回到主屏幕以后标题为压测结束
+func (d *dashboardView) lines() (lines []string) {
+	s := d.last
+	if s == nil {
+		return []string{"等待统计数据..."}
+	}
+	title := "goapistress 压测中  Ctrl+C 停止并输出结果"
+	if !d.started {
+		title = "goapistress 压测结束"
+	}
+	lines = append(lines, fmt.Sprintf("%s  耗时:%.0fs  并发数:%d", title, s.requestTime, s.chanIDLen))
+	lines = append(lines, d.progress(s)...)
+	total := s.successNum + s.failureNum
+	var errorRate float64
+	if total > 0 {
+		errorRate = float64(s.failureNum) * 100 / float64(total)
+	}
+	summary := fmt.Sprintf("成功数:%d  失败数:%d  错误率:%.2f%%  qps:%.2f", s.successNum, s.failureNum, errorRate, s.qps)
+	if d.task.IsOpen() {
+		summary = summary + fmt.Sprintf("  丢弃数:%d", s.dropped)
+	}
+	if d.task.IsStaged() {
+		summary = summary + fmt.Sprintf("  阶段:%d 目标:%.1f", s.stage+1, s.target)
+	}
+	lines = append(lines, summary, "")
+
+	sparkWidth := d.width - 2
+	lines = append(lines, fmt.Sprintf("吞吐量(qps)  当前:%.2f  最高:%.2f", last(d.qps), maxValue(d.qps)),
+		" "+sparkline(d.qps, sparkWidth), "")
+	name := "耗时"
+	if len(percentiles) > 0 {
+		name = PercentileName(percentiles[len(percentiles)-1])
+	}
+	lines = append(lines, fmt.Sprintf("每秒%s(ms)  当前:%.2f  最高:%.2f", name, last(d.latency), maxValue(d.latency)),
+		" "+sparkline(d.latency, sparkWidth), "")
+
+	current := fmt.Sprintf("累计耗时(ms)  平均:%.2f  最短:%.2f  最长:%.2f", s.averageTime, s.minTime, s.maxTime)
+	for i, percent := range percentiles {
+		current = current + fmt.Sprintf("  %s:%.2f", PercentileName(percent), float64(s.percentiles[i])/1e6)
+	}
+	lines = append(lines, current)
+	lines = append(lines, fmt.Sprintf("下载字节:%s (%s/s)  上传字节:%s (%s/s)", p.Sprintf("%d", s.receivedBytes),
+		p.Sprintf("%d", s.speed()), p.Sprintf("%d", s.sentBytes), p.Sprintf("%d", s.sendSpeed())), "")
+
+	lines = append(lines, "错误码:")
+	codes := make([]int, 0, len(d.errCode))
+	var count int
+	for code, n := range d.errCode {
+		codes = append(codes, code)
+		count = count + n
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return d.errCode[codes[i]] > d.errCode[codes[j]]
+	})
+	for _, code := range codes {
+		lines = append(lines, fmt.Sprintf("%10d %7.2f%%  %s", d.errCode[code],
+			float64(d.errCode[code])*100/float64(count), model.ErrCodeString(code)))
+	}
+	return
+}
+
+// progress 请求数、压测时长的进度
+func (d *dashboardView) progress(s *snapshot) (lines []string) {
+	barWidth := d.width - 40
+	if barWidth > 50 {
+		barWidth = 50
+	}
+	// 请求总数 并发数*请求数，开放模型同样为 -c * -n
+	if total := d.task.Concurrency * d.task.Number; total > 0 {
+		done := s.successNum + s.failureNum
+		lines = append(lines, fmt.Sprintf("请求进度 %s %d/%d", bar(float64(done)/float64(total), barWidth), done,
+			total))
+	}
+	if d.task.Duration > 0 {
+		lines = append(lines, fmt.Sprintf("时长进度 %s %.0fs/%.0fs", bar(s.requestTime/d.task.Duration.Seconds(),
+			barWidth), s.requestTime, d.task.Duration.Seconds()))
+	}
+	return
+}
+
+// bar 进度条 ratio 取值 0~1
+func bar(ratio float64, width int) string {
+	if width < 1 {
+		width = 1
+	}
+	ratio = math.Max(0, math.Min(1, ratio))
+	filled := int(ratio * float64(width))
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]" +
+		fmt.Sprintf(" %5.1f%%", ratio*100)
+}
+
+// sparkline 迷你折线图 只显示最近 width 个值，按其中的最大值缩放
+func sparkline(values []float64, width int) string {
+	if width < 1 || len(values) == 0 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	top := maxValue(values)
+	runes := make([]rune, len(values))
+	for i, value := range values {
+		index := 0
+		if top > 0 {
+			index = int(value / top * float64(len(sparkBlocks)-1))
+		}
+		runes[i] = sparkBlocks[index]
+	}
+	return string(runes)
+}
+
+// last 最后一个值
+func last(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return values[len(values)-1]
+}
+
+// maxValue 最大值
+func maxValue(values []float64) (max float64) {
+	for _, value := range values {
+		if value > max {
+			max = value
+		}
+	}
+	return
+}
+
+// truncate 按显示宽度截断 中文等宽字符占两列
+func truncate(line string, width int) string {
+	var (
+		b     strings.Builder
+		count int
+	)
+	for _, r := range line {
+		w := 1
+		if r >= 0x1100 && utf8.RuneLen(r) > 1 && !(r >= 0x2580 && r <= 0x259f) {
+			w = 2
+		}
+		if count+w > width {
+			break
+		}
+		count = count + w
+		b.WriteRune(r)
+	}
+	return b.String()
+}
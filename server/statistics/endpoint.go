@@ -0,0 +1,133 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"goapistress/model"
+)
+
+// endpoints 分接口统计
+type endpoints struct {
+	list map[string]*Result // 接口名称/统计结果
+}
+
+// newEndpoints 分接口统计
+func newEndpoints() *endpoints {
+	return &endpoints{
+		list: make(map[string]*Result),
+	}
+}
+
+// add 记录一个请求结果 分步压测时按每一步的接口记录
+func (e *endpoints) add(data *model.RequestResults) {
+	if len(data.Steps) == 0 {
+		e.record(data)
+		return
+	}
+	for _, step := range data.Steps {
+		e.record(step)
+	}
+}
+
+// record 按接口名称记录
+func (e *endpoints) record(data *model.RequestResults) {
+	result, ok := e.list[data.Endpoint]
+	if !ok {
+		result = &Result{
+			Name:    data.Endpoint,
+			ErrCode: make(map[int]int),
+			latency: NewHistogram(),
+		}
+		e.list[data.Endpoint] = result
+	}
+	if data.IsSucceed {
+		result.SuccessNum = result.SuccessNum + 1
+	} else {
+		result.FailureNum = result.FailureNum + 1
+	}
+	result.ProcessingTime = result.ProcessingTime + data.Time
+	if result.MaxTime < data.Time {
+		result.MaxTime = data.Time
+	}
+	if result.MinTime == 0 || result.MinTime > data.Time {
+		result.MinTime = data.Time
+	}
+	result.ReceivedBytes = result.ReceivedBytes + data.ReceivedBytes
+	result.SentBytes = result.SentBytes + data.SentBytes
+	result.ErrCode[data.ErrCode] = result.ErrCode[data.ErrCode] + 1
+	result.latency.Record(data.Time)
+}
+
+// results 各接口的统计结果 按名称排序，只有一个接口时返回 nil
+// requestTime 压测时长 纳秒
+func (e *endpoints) results(requestTime uint64) (list []*Result) {
+	for _, result := range e.list {
+		result.RequestTime = requestTime
+		if requestTime != 0 {
+			result.QPS = float64(result.SuccessNum*1e9) / float64(requestTime)
+		}
+		list = append(list, result)
+	}
+	if len(list) <= 1 {
+		return nil
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return
+}
+
+// find 按名称查找接口的统计结果 需要在 results 之后调用
+func (e *endpoints) find(name string) *Result {
+	return e.list[name]
+}
+
+// printEndpoints 输出分接口统计 时长都为毫秒
+func printEndpoints(result *Result) {
+	if len(result.Endpoints) == 0 {
+		return
+	}
+	fmt.Println("分接口统计:")
+	fmt.Println("   请求数│  成功数│  失败数│   qps  │  错误率│平均耗时│" + percentileTitles() + "│ 接口 状态码")
+	for _, endpoint := range append(result.Endpoints, result) {
+		name := endpoint.Name
+		if endpoint == result {
+			name = "合计"
+		}
+		fmt.Printf("%9d│%8d│%8d│%8.2f│%7.2f%%│%8.2f│%s│ %s %s\n", endpoint.Total(), endpoint.SuccessNum,
+			endpoint.FailureNum, endpoint.QPS, endpoint.ErrorRate(), float64(endpoint.AverageTime())/1e6,
+			percentileValues(endpoint.latency), name, errCodeString(endpoint.ErrCode))
+	}
+}
+
+// percentileTitles 百分位表头
+func percentileTitles() string {
+	titles := make([]string, len(percentiles))
+	for i, percent := range percentiles {
+		titles[i] = fmt.Sprintf("%8s", PercentileName(percent))
+	}
+	return strings.Join(titles, "│")
+}
+
+// percentileValues 百分位 纳秒=>毫秒
+func percentileValues(histogram *Histogram) string {
+	values := histogram.Percentiles(percentiles)
+	arr := make([]string, len(values))
+	for i, value := range values {
+		arr[i] = fmt.Sprintf("%8.2f", float64(value)/1e6)
+	}
+	return strings.Join(arr, "│")
+}
+
+// errCodeString 输出错误码、次数 与 printMap 格式相同
+func errCodeString(errCode map[int]int) string {
+	var mapArr []string
+	for key, value := range errCode {
+		mapArr = append(mapArr, fmt.Sprintf("%v:%v", key, value))
+	}
+	sort.Strings(mapArr)
+	return strings.Join(mapArr, ";")
+}
@@ -0,0 +1,106 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"fmt"
+	"sort"
+
+	"goapistress/model"
+)
+
+const (
+	// errorMessageLimit 最多记录的不同错误信息数 超过后新的错误信息不再记录
+	errorMessageLimit = 1000
+	// errorSampleTop 结果中保留的错误信息数
+	errorSampleTop = 10
+)
+
+// ErrorSample 错误信息及出现次数
+type ErrorSample struct {
+	Message string // 原始错误信息
+	Code    int    // 错误码
+	Count   uint64 // 出现次数
+}
+
+// errorSamples 错误信息统计
+type errorSamples struct {
+	list map[string]*ErrorSample // 错误信息/统计
+}
+
+// newErrorSamples 错误信息统计
+func newErrorSamples() *errorSamples {
+	return &errorSamples{
+		list: make(map[string]*ErrorSample),
+	}
+}
+
+// add 记录一个请求结果 分步压测时记录每一步
+func (e *errorSamples) add(data *model.RequestResults) {
+	if len(data.Steps) == 0 {
+		e.record(data)
+		return
+	}
+	for _, step := range data.Steps {
+		e.record(step)
+	}
+}
+
+// record 记录错误信息
+func (e *errorSamples) record(data *model.RequestResults) {
+	if data.ErrMsg == "" {
+		return
+	}
+	sample, ok := e.list[data.ErrMsg]
+	if !ok {
+		if len(e.list) >= errorMessageLimit {
+			return
+		}
+		sample = &ErrorSample{Message: data.ErrMsg, Code: data.ErrCode}
+		e.list[data.ErrMsg] = sample
+	}
+	sample.Count++
+}
+
+// results 出现次数最多的错误信息 没有错误信息时返回 nil
+func (e *errorSamples) results() []ErrorSample {
+	if len(e.list) == 0 {
+		return nil
+	}
+	samples := make([]ErrorSample, 0, len(e.list))
+	for _, sample := range e.list {
+		samples = append(samples, *sample)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].Count != samples[j].Count {
+			return samples[i].Count > samples[j].Count
+		}
+		return samples[i].Message < samples[j].Message
+	})
+	if len(samples) > errorSampleTop {
+		samples = samples[:errorSampleTop]
+	}
+	return samples
+}
+
+// printErrors 输出错误分类和出现次数最多的错误信息
+func printErrors(result *Result) {
+	codes := make([]int, 0, len(result.ErrCode))
+	for code := range result.ErrCode {
+		if model.ErrName(code) != "" {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	if len(codes) > 0 {
+		fmt.Println("错误分类:")
+		for _, code := range codes {
+			fmt.Printf("%9d│ %s\n", result.ErrCode[code], model.ErrCodeString(code))
+		}
+	}
+	if len(result.ErrorSamples) > 0 {
+		fmt.Println("错误信息(出现次数最多的前", errorSampleTop, "条):")
+		for _, sample := range result.ErrorSamples {
+			fmt.Printf("%9d│ %s %s\n", sample.Count, model.ErrCodeString(sample.Code), sample.Message)
+		}
+	}
+}
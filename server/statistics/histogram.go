@@ -0,0 +1,294 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultDigits 默认有效数字位数
+	defaultDigits = 3
+	// highestTrackable 可记录的最大耗时 纳秒，超出的按最大值记录
+	highestTrackable = uint64(3600 * 1e9)
+)
+
+var (
+	// histogramDigits 耗时直方图的有效数字位数
+	histogramDigits = defaultDigits
+	// percentiles 输出的耗时百分位
+	percentiles = []float64{90, 95, 99}
+)
+
+// SetPrecision 设置耗时直方图的有效数字位数 1~4，位数越多百分位越精确，占用内存越多
+// 4 位时每个用到的桶(耗时范围翻倍一次)占 128KB，5 位时为 1MB，每秒统计、每个接口、错误码都有直方图，不再支持
+func SetPrecision(digits int) error {
+	if digits < 1 || digits > 4 {
+		return fmt.Errorf("有效数字位数不合法:%d 取值:1~4", digits)
+	}
+	histogramDigits = digits
+	return nil
+}
+
+// SetPercentiles 设置输出的耗时百分位 示例:50,75,90,99,99.9,99.99
+// 百分位输出到结果汇总、每秒统计表格和导出的数据中
+func SetPercentiles(str string) error {
+	var list []float64
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		percent, err := strconv.ParseFloat(item, 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return fmt.Errorf("百分位不合法:%s 取值:(0,100]", item)
+		}
+		list = append(list, percent)
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("百分位不能为空")
+	}
+	sort.Float64s(list)
+	percentiles = list
+	return nil
+}
+
+// Percentiles 输出的耗时百分位
+func Percentiles() []float64 {
+	return append([]float64(nil), percentiles...)
+}
+
+// PercentileName 百分位名称 示例:tp99.9
+func PercentileName(percent float64) string {
+	return "tp" + strconv.FormatFloat(percent, 'f', -1, 64)
+}
+
+// Histogram 耗时直方图 HdrHistogram 方式的对数线性分桶
+// 内存只与有效数字位数、记录到的耗时范围有关，与请求数无关，可以合并
+// 子桶次数按桶分块，第一次记录到某个桶时才分配，耗时集中时只占用少数几块
+type Histogram struct {
+	halfMagnitude uint       // 每个桶一半子桶数的位数
+	halfCount     uint64     // 每个桶一半的子桶数 也是每块的子桶数
+	highest       uint64     // 最大可记录值
+	counts        [][]uint64 // 每个子桶的次数 按块分配，未分配的块次数都为0
+	total         uint64     // 总次数
+	sum           uint64     // 总和
+	min           uint64     // 最小值
+	max           uint64     // 最大值
+}
+
+// NewHistogram 耗时直方图 有效数字位数为 SetPrecision 设置的值
+func NewHistogram() *Histogram {
+	return newHistogram(histogramDigits, highestTrackable)
+}
+
+// newHistogram 耗时直方图
+// digits 有效数字位数，保证在 digits 位有效数字内的精度
+// highest 最大可记录值
+func newHistogram(digits int, highest uint64) *Histogram {
+	// 单位精度可以表示的最大值为 2*10^digits
+	largest := 2 * math.Pow10(digits)
+	magnitude := uint(math.Ceil(math.Log2(largest)))
+	if magnitude > 0 {
+		magnitude = magnitude - 1
+	}
+	h := &Histogram{
+		halfMagnitude: magnitude,
+		halfCount:     1 << magnitude,
+		highest:       highest,
+	}
+	h.counts = make([][]uint64, h.countsIndex(highest)>>magnitude+1)
+	return h
+}
+
+// countsIndex 值对应的子桶下标
+func (h *Histogram) countsIndex(value uint64) int {
+	mask := h.halfCount<<1 - 1
+	bucket := 64 - int(h.halfMagnitude) - 1 - bits.LeadingZeros64(value|mask)
+	sub := value >> uint(bucket)
+	return int(uint64(bucket+1)<<h.halfMagnitude + sub - h.halfCount)
+}
+
+// valueRange 子桶下标对应值的范围 [lowest, highest]
+func (h *Histogram) valueRange(index int) (lowest, highest uint64) {
+	bucket := index>>h.halfMagnitude - 1
+	sub := uint64(index)&(h.halfCount-1) + h.halfCount
+	if bucket < 0 {
+		sub = sub - h.halfCount
+		bucket = 0
+	}
+	lowest = sub << uint(bucket)
+	return lowest, lowest + 1<<uint(bucket) - 1
+}
+
+// Record 记录一个值
+func (h *Histogram) Record(value uint64) {
+	if h.total == 0 || value < h.min {
+		h.min = value
+	}
+	if value > h.max {
+		h.max = value
+	}
+	h.total = h.total + 1
+	h.sum = h.sum + value
+	if value > h.highest {
+		value = h.highest
+	}
+	index := h.countsIndex(value)
+	chunk := h.counts[index>>h.halfMagnitude]
+	if chunk == nil {
+		chunk = make([]uint64, h.halfCount)
+		h.counts[index>>h.halfMagnitude] = chunk
+	}
+	chunk[uint64(index)&(h.halfCount-1)]++
+}
+
+// Merge 合并另一个直方图，两个直方图的有效数字位数需要相同
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other.total == 0 {
+		return
+	}
+	if other.halfMagnitude != h.halfMagnitude || len(other.counts) != len(h.counts) {
+		panic("Merge 直方图精度不一致")
+	}
+	for i, chunk := range other.counts {
+		if chunk == nil {
+			continue
+		}
+		if h.counts[i] == nil {
+			h.counts[i] = make([]uint64, h.halfCount)
+		}
+		for j, count := range chunk {
+			h.counts[i][j] = h.counts[i][j] + count
+		}
+	}
+	if h.total == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.total = h.total + other.total
+	h.sum = h.sum + other.sum
+}
+
+// Reset 清空 保留已分配的块，每秒统计重复使用
+func (h *Histogram) Reset() {
+	for _, chunk := range h.counts {
+		for j := range chunk {
+			chunk[j] = 0
+		}
+	}
+	h.total, h.sum, h.min, h.max = 0, 0, 0, 0
+}
+
+// Count 总次数
+func (h *Histogram) Count() uint64 {
+	return h.total
+}
+
+// Min 最小值
+func (h *Histogram) Min() uint64 {
+	return h.min
+}
+
+// Max 最大值
+func (h *Histogram) Max() uint64 {
+	return h.max
+}
+
+// Mean 平均值
+func (h *Histogram) Mean() uint64 {
+	if h.total == 0 {
+		return 0
+	}
+	return h.sum / h.total
+}
+
+// Bucket 直方图的一个子桶 值的范围 [Lowest, Highest]
+type Bucket struct {
+	Lowest  uint64 // 最小值
+	Highest uint64 // 最大值
+	Count   uint64 // 次数
+}
+
+// Buckets 所有次数不为0的子桶 按值从小到大
+func (h *Histogram) Buckets() (buckets []Bucket) {
+	if h == nil {
+		return
+	}
+	h.each(func(index int, count uint64) bool {
+		lowest, highest := h.valueRange(index)
+		buckets = append(buckets, Bucket{Lowest: lowest, Highest: highest, Count: count})
+		return true
+	})
+	return
+}
+
+// each 按值从小到大遍历次数不为0的子桶 fn 返回 false 时停止
+func (h *Histogram) each(fn func(index int, count uint64) bool) {
+	for i, chunk := range h.counts {
+		for j, count := range chunk {
+			if count != 0 && !fn(i<<h.halfMagnitude+j, count) {
+				return
+			}
+		}
+	}
+}
+
+// Percentile 百分位 percent 取值 0~100，返回所在子桶的最大值
+func (h *Histogram) Percentile(percent float64) uint64 {
+	return h.Percentiles([]float64{percent})[0]
+}
+
+// Percentiles 多个百分位 遍历一次子桶，返回值与 list 一一对应
+func (h *Histogram) Percentiles(list []float64) (values []uint64) {
+	values = make([]uint64, len(list))
+	if h == nil || h.total == 0 {
+		return
+	}
+	// 按百分位从小到大依次查找
+	order := make([]int, len(list))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return list[order[i]] < list[order[j]]
+	})
+	// 每个百分位需要的次数
+	targets := make([]uint64, len(list))
+	for i, percent := range list {
+		values[i] = h.max
+		targets[i] = uint64(percent/100*float64(h.total) + 0.5)
+		if targets[i] == 0 {
+			targets[i] = 1
+		}
+	}
+	var (
+		count uint64
+		next  int // order 中下一个要查找的百分位
+	)
+	h.each(func(index int, n uint64) bool {
+		count = count + n
+		for ; next < len(order) && count >= targets[order[next]]; next++ {
+			i := order[next]
+			if list[i] >= 100 {
+				continue
+			}
+			_, highest := h.valueRange(index)
+			if highest > h.max {
+				highest = h.max
+			}
+			if highest < h.min {
+				highest = h.min
+			}
+			values[i] = highest
+		}
+		return next < len(order)
+	})
+	return
+}
@@ -0,0 +1,134 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestHistogramPercentile 测试百分位精度
+func TestHistogramPercentile(t *testing.T) {
+	h := newHistogram(3, highestTrackable)
+	// 1ms ~ 1000ms
+	for i := uint64(1); i <= 1000; i++ {
+		h.Record(i * 1e6)
+	}
+	tt := map[string]struct {
+		percent float64
+		value   uint64
+	}{
+		"p50":  {percent: 50, value: 500 * 1e6},
+		"p90":  {percent: 90, value: 900 * 1e6},
+		"p99":  {percent: 99, value: 990 * 1e6},
+		"p999": {percent: 99.9, value: 999 * 1e6},
+		"max":  {percent: 100, value: 1000 * 1e6},
+	}
+	for name, value := range tt {
+		actual := h.Percentile(value.percent)
+		// 3 位有效数字 误差不超过千分之一
+		if actual < value.value || actual > value.value+value.value/1000 {
+			t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, value.value, actual)
+		}
+	}
+	if h.Count() != 1000 || h.Min() != 1e6 || h.Max() != 1000*1e6 || h.Mean() != 500500000 {
+		t.Errorf("数据不一致 实际:%d %d %d %d", h.Count(), h.Min(), h.Max(), h.Mean())
+	}
+}
+
+// TestHistogramMerge 测试合并
+func TestHistogramMerge(t *testing.T) {
+	a := newHistogram(2, highestTrackable)
+	b := newHistogram(2, highestTrackable)
+	all := newHistogram(2, highestTrackable)
+	for i := uint64(1); i <= 100; i++ {
+		a.Record(i * 1e5)
+		all.Record(i * 1e5)
+		b.Record(i * 1e7)
+		all.Record(i * 1e7)
+	}
+	a.Merge(b)
+	for _, percent := range []float64{10, 50, 75, 99, 100} {
+		if a.Percentile(percent) != all.Percentile(percent) {
+			t.Errorf("p%v 数据不一致 预期:%v 实际:%v", percent, all.Percentile(percent), a.Percentile(percent))
+		}
+	}
+	if a.Count() != 200 || a.Min() != 1e5 || a.Max() != 1e9 {
+		t.Errorf("数据不一致 实际:%d %d %d", a.Count(), a.Min(), a.Max())
+	}
+}
+
+// TestHistogramOverflow 测试超出最大可记录值
+func TestHistogramOverflow(t *testing.T) {
+	h := newHistogram(1, 1e6)
+	h.Record(5e6)
+	if h.Percentile(50) != 5e6 || h.Max() != 5e6 {
+		t.Errorf("数据不一致 预期:%v 实际:%v", uint64(5e6), h.Percentile(50))
+	}
+}
+
+// TestSetPercentiles 测试百分位参数解析
+func TestSetPercentiles(t *testing.T) {
+	defer func() {
+		percentiles = []float64{90, 95, 99}
+	}()
+	tt := map[string]struct {
+		str   string
+		list  []float64
+		isErr bool
+	}{
+		"list":  {str: "99.9, 50,99", list: []float64{50, 99, 99.9}},
+		"empty": {str: "", isErr: true},
+		"zero":  {str: "0,50", isErr: true},
+		"over":  {str: "100.1", isErr: true},
+	}
+	for name, value := range tt {
+		err := SetPercentiles(value.str)
+		if (err != nil) != value.isErr {
+			t.Errorf("%s 错误不一致 预期:%v 实际:%v", name, value.isErr, err)
+			continue
+		}
+		if !value.isErr && !reflect.DeepEqual(Percentiles(), value.list) {
+			t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, value.list, Percentiles())
+		}
+	}
+	h := newHistogram(3, highestTrackable)
+	for i := uint64(1); i <= 1000; i++ {
+		h.Record(i)
+	}
+	list := []float64{99.9, 50, 100, 99}
+	values := h.Percentiles(list)
+	for i, percent := range list {
+		if values[i] != h.Percentile(percent) {
+			t.Errorf("p%v 数据不一致 预期:%v 实际:%v", percent, h.Percentile(percent), values[i])
+		}
+	}
+}
+
+// TestHistogramLazy 测试按块分配 只分配记录到的耗时范围，合并、清空以后仍然正确
+func TestHistogramLazy(t *testing.T) {
+	h := newHistogram(4, highestTrackable)
+	h.Record(5e6)
+	h.Record(6e6)
+	var chunks int
+	for _, chunk := range h.counts {
+		if chunk != nil {
+			chunks++
+		}
+	}
+	if chunks != 1 {
+		t.Errorf("数据不一致 预期:%v 实际:%v", 1, chunks)
+	}
+	other := newHistogram(4, highestTrackable)
+	other.Record(2e9)
+	h.Merge(other)
+	if h.Percentile(50) < 6e6 || h.Percentile(50) > 6e6+6e6/1e4 || h.Percentile(100) != 2e9 {
+		t.Errorf("数据不一致 实际:%v %v", h.Percentile(50), h.Percentile(100))
+	}
+	h.Reset()
+	if h.Count() != 0 || len(h.Buckets()) != 0 || h.Percentile(99) != 0 {
+		t.Errorf("数据不一致 实际:%v %v", h.Count(), h.Buckets())
+	}
+	if err := SetPrecision(5); err == nil {
+		t.Errorf("数据不一致 预期:有效数字位数不合法 实际:%v", err)
+	}
+}
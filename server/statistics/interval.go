@@ -0,0 +1,111 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"time"
+
+	"goapistress/model"
+)
+
+// Interval 一个统计周期(默认1秒)内的数据 时长都为毫秒
+type Interval struct {
+	Time          time.Time          `json:"time"`           // 周期结束时间
+	Elapsed       float64            `json:"elapsed"`        // 压测耗时 秒
+	Workers       int                `json:"workers"`        // 并发数
+	SuccessNum    uint64             `json:"success"`        // 周期内成功数
+	FailureNum    uint64             `json:"failure"`        // 周期内失败数
+	Dropped       uint64             `json:"dropped"`        // 周期内开放模型丢弃数
+	QPS           float64            `json:"qps"`            // 周期内 成功数/周期时长
+	AverageTime   float64            `json:"avg_ms"`         // 周期内平均耗时
+	MaxTime       float64            `json:"max_ms"`         // 周期内最长耗时
+	Percentiles   map[string]float64 `json:"percentiles_ms"` // 周期内耗时百分位 tp99:耗时
+	ReceivedBytes int64              `json:"received_bytes"` // 周期内下载字节
+	SentBytes     int64              `json:"sent_bytes"`     // 周期内上传字节
+	ErrCode       map[int]int        `json:"err_code"`       // 周期内错误码/错误个数
+}
+
+// intervalStat 统计周期内的数据 每个周期结束后清空
+type intervalStat struct {
+	start          time.Time   // 周期开始时间
+	successNum     uint64      // 成功数
+	failureNum     uint64      // 失败数
+	processingTime uint64      // 耗时之和
+	receivedBytes  int64       // 下载字节
+	sentBytes      int64       // 上传字节
+	errCode        map[int]int // 错误码/错误个数
+	latency        *Histogram  // 耗时分布
+	dropped        uint64      // 上个周期结束时的累计丢弃数
+}
+
+// newIntervalStat 统计周期
+func newIntervalStat(start time.Time) *intervalStat {
+	return &intervalStat{
+		start:   start,
+		errCode: make(map[int]int),
+		latency: NewHistogram(),
+	}
+}
+
+// add 记录一个请求结果
+func (s *intervalStat) add(data *model.RequestResults) {
+	if data.IsSucceed {
+		s.successNum = s.successNum + 1
+	} else {
+		s.failureNum = s.failureNum + 1
+	}
+	s.processingTime = s.processingTime + data.Time
+	s.receivedBytes = s.receivedBytes + data.ReceivedBytes
+	s.sentBytes = s.sentBytes + data.SentBytes
+	s.errCode[data.ErrCode] = s.errCode[data.ErrCode] + 1
+	s.latency.Record(data.Time)
+}
+
+// result 当前周期的统计结果 用于判定提前结束条件
+func (s *intervalStat) result(now time.Time) *Result {
+	result := &Result{
+		SuccessNum:     s.successNum,
+		FailureNum:     s.failureNum,
+		RequestTime:    uint64(now.Sub(s.start)),
+		ProcessingTime: s.processingTime,
+		MaxTime:        s.latency.Max(),
+		MinTime:        s.latency.Min(),
+		latency:        s.latency,
+	}
+	if result.RequestTime > 0 {
+		result.QPS = float64(s.successNum) * 1e9 / float64(result.RequestTime)
+	}
+	return result
+}
+
+// next 结束当前周期，返回周期内的数据并开始下一个周期
+// elapsed 压测耗时 workers 并发数 dropped 累计丢弃数
+func (s *intervalStat) next(now time.Time, elapsed time.Duration, workers int, dropped uint64) *Interval {
+	interval := &Interval{
+		Time:          now,
+		Elapsed:       elapsed.Seconds(),
+		Workers:       workers,
+		SuccessNum:    s.successNum,
+		FailureNum:    s.failureNum,
+		Dropped:       dropped - s.dropped,
+		MaxTime:       float64(s.latency.Max()) / 1e6,
+		Percentiles:   make(map[string]float64),
+		ReceivedBytes: s.receivedBytes,
+		SentBytes:     s.sentBytes,
+		ErrCode:       s.errCode,
+	}
+	if seconds := now.Sub(s.start).Seconds(); seconds > 0 {
+		interval.QPS = float64(s.successNum) / seconds
+	}
+	if total := s.successNum + s.failureNum; total > 0 {
+		interval.AverageTime = float64(s.processingTime) / float64(total) / 1e6
+	}
+	for i, value := range s.latency.Percentiles(percentiles) {
+		interval.Percentiles[PercentileName(percentiles[i])] = float64(value) / 1e6
+	}
+	s.start = now
+	s.successNum, s.failureNum, s.processingTime, s.receivedBytes, s.sentBytes = 0, 0, 0, 0, 0
+	s.errCode = make(map[int]int)
+	s.latency.Reset()
+	s.dropped = dropped
+	return interval
+}
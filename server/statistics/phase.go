@@ -0,0 +1,87 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"fmt"
+
+	"goapistress/model"
+)
+
+// PhaseNames HTTP 请求阶段 与 model.Phases 的字段一一对应
+var PhaseNames = []string{"dns", "connect", "tls", "ttfb", "download"}
+
+// phaseTitles HTTP 请求阶段的显示名称
+var phaseTitles = map[string]string{
+	"dns":      "DNS 解析",
+	"connect":  "TCP 连接",
+	"tls":      "TLS 握手",
+	"ttfb":     "首字节",
+	"download": "下载",
+}
+
+// phases HTTP 请求各阶段耗时分布
+type phases struct {
+	list map[string]*Histogram // 阶段/耗时分布
+}
+
+// newPhases HTTP 请求各阶段耗时分布
+func newPhases() *phases {
+	return &phases{
+		list: make(map[string]*Histogram),
+	}
+}
+
+// add 记录一个请求结果 分步压测时记录每一步
+func (p *phases) add(data *model.RequestResults) {
+	if len(data.Steps) == 0 {
+		p.record(data.Phases)
+		return
+	}
+	for _, step := range data.Steps {
+		p.record(step.Phases)
+	}
+}
+
+// record 记录各阶段耗时 复用连接时没有 DNS、连接、TLS 阶段，不记录
+func (p *phases) record(value *model.Phases) {
+	if value == nil {
+		return
+	}
+	values := []uint64{value.DNS, value.Connect, value.TLS, value.TTFB, value.Download}
+	for i, name := range PhaseNames {
+		if values[i] == 0 && i < 3 {
+			continue
+		}
+		histogram, ok := p.list[name]
+		if !ok {
+			histogram = NewHistogram()
+			p.list[name] = histogram
+		}
+		histogram.Record(values[i])
+	}
+}
+
+// results 各阶段耗时分布 没有记录时返回 nil
+func (p *phases) results() map[string]*Histogram {
+	if len(p.list) == 0 {
+		return nil
+	}
+	return p.list
+}
+
+// printPhases 输出 HTTP 请求各阶段耗时 时长都为毫秒
+func printPhases(list map[string]*Histogram) {
+	if len(list) == 0 {
+		return
+	}
+	fmt.Println("HTTP 请求各阶段耗时(复用连接时没有 DNS、连接、TLS 阶段):")
+	fmt.Println("     次数│平均耗时│最长耗时│" + percentileTitles() + "│ 阶段")
+	for _, name := range PhaseNames {
+		histogram, ok := list[name]
+		if !ok {
+			continue
+		}
+		fmt.Printf("%9d│%8.2f│%8.2f│%s│ %s\n", histogram.Count(), float64(histogram.Mean())/1e6,
+			float64(histogram.Max())/1e6, percentileValues(histogram), phaseTitles[name])
+	}
+}
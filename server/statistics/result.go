@@ -0,0 +1,87 @@
+// Package statistics 统计数据
+package statistics
+
+// Result 压测结果汇总 时间都是纳秒
+type Result struct {
+	Name           string                // 接口名称 分接口统计时有值
+	Concurrency    uint64                // 协程数
+	SuccessNum     uint64                // 成功数
+	FailureNum     uint64                // 失败数
+	Dropped        uint64                // 开放模型丢弃数
+	RequestTime    uint64                // 总请求时间
+	ProcessingTime uint64                // 所有请求耗时之和
+	QPS            float64               // qps
+	MaxTime        uint64                // 最长耗时
+	MinTime        uint64                // 最短耗时
+	ReceivedBytes  int64                 // 下载字节
+	SentBytes      int64                 // 上传字节
+	ErrCode        map[int]int           // 错误码/错误个数
+	WarmUpNum      uint64                // 预热请求数 不计入统计
+	Endpoints      []*Result             // 分接口统计 压测多个接口或分步压测时有值
+	Intervals      []*Interval           // 每个统计周期的数据
+	Phases         map[string]*Histogram // HTTP 请求各阶段耗时分布 阶段见 PhaseNames
+	ErrorSamples   []ErrorSample         // 出现次数最多的错误信息
+	Codes          []*Breakdown          // 按错误码分组的耗时和响应大小
+	Outcomes       []*Breakdown          // 按成功/失败分组的耗时和响应大小
+	Thresholds     []*ThresholdResult    // 判定结果 设置了判定条件时有值
+	AbortReason    string                // 提前结束压测的原因 未提前结束时为空
+	latency        *Histogram            // 请求响应时间分布
+	corrected      *Histogram            // 协调遗漏修正后的响应时间分布
+}
+
+// Total 请求总数
+func (r *Result) Total() uint64 {
+	return r.SuccessNum + r.FailureNum
+}
+
+// Passed 是否通过 未提前结束且判定条件都满足，没有设置判定条件时有成功的请求即通过
+func (r *Result) Passed() bool {
+	if r.AbortReason != "" {
+		return false
+	}
+	if len(r.Thresholds) == 0 {
+		return r.Total() == 0 || r.SuccessNum > 0
+	}
+	for _, item := range r.Thresholds {
+		if !item.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// ErrorRate 错误率 百分比
+func (r *Result) ErrorRate() float64 {
+	if r.Total() == 0 {
+		return 0
+	}
+	return float64(r.FailureNum) * 100 / float64(r.Total())
+}
+
+// AverageTime 平均耗时
+func (r *Result) AverageTime() uint64 {
+	if r.Total() == 0 {
+		return 0
+	}
+	return r.ProcessingTime / r.Total()
+}
+
+// Percentile 耗时百分位 percent 取值 0~100
+func (r *Result) Percentile(percent float64) uint64 {
+	return r.latency.Percentile(percent)
+}
+
+// Latency 耗时分布
+func (r *Result) Latency() *Histogram {
+	return r.latency
+}
+
+// Corrected 协调遗漏修正后的耗时分布 未开启修正时为 nil
+func (r *Result) Corrected() *Histogram {
+	return r.corrected
+}
+
+// CorrectedPercentile 协调遗漏修正后的耗时百分位
+func (r *Result) CorrectedPercentile(percent float64) uint64 {
+	return r.corrected.Percentile(percent)
+}
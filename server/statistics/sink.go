@@ -0,0 +1,53 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"sync"
+
+	"goapistress/tools"
+)
+
+// IntervalSink 统计周期数据的输出 时间序列文件、StatsD、InfluxDB 等
+type IntervalSink interface {
+	// Write 输出一个统计周期的数据 在统计协程中调用，不能阻塞
+	Write(interval *Interval) error
+	// Close 关闭输出
+	Close() error
+}
+
+var (
+	// sinks 统计周期数据的输出
+	sinks      []IntervalSink
+	sinksMutex sync.Mutex
+)
+
+// RegisterIntervalSink 注册统计周期数据的输出 每个统计周期(1秒)结束时调用
+func RegisterIntervalSink(sink IntervalSink) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	sinks = append(sinks, sink)
+}
+
+// CloseIntervalSinks 关闭所有统计周期数据的输出
+func CloseIntervalSinks() (err error) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	for _, sink := range sinks {
+		if closeErr := sink.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	sinks = nil
+	return
+}
+
+// exportInterval 输出一个统计周期的数据
+func exportInterval(interval *Interval) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	for _, sink := range sinks {
+		if err := sink.Write(interval); err != nil {
+			tools.Println("统计数据输出失败:", err)
+		}
+	}
+}
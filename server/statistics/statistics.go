@@ -8,8 +8,6 @@ import (
 	"sync"
 	"time"
 
-	"goapistress/tools"
-
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 
@@ -20,16 +18,15 @@ var (
 	// 输出统计数据的时间
 	exportStatisticsTime = 1 * time.Second
 	p                    = message.NewPrinter(language.English)
-	requestTimeList      []uint64 // 所有请求响应时间
 )
 
-// ReceivingResults 接收结果并处理
+// ReceivingResults 接收结果并处理，ch 关闭以后返回压测结果
 // 统计的时间都是纳秒，显示的时间 都是毫秒
-// concurrent 并发数
-func ReceivingResults(concurrent uint64, ch <-chan *model.RequestResults, wg *sync.WaitGroup) {
-	defer func() {
-		wg.Done()
-	}()
+// task 压测任务参数
+// status 压测运行状态
+// warm 预热阶段 请求结果在 ch 之前已经由 warm.Mark 标记
+func ReceivingResults(task *model.TaskForm, status *model.TaskStatus, warm *WarmUp,
+	ch <-chan *model.RequestResults) (result *Result) {
 	var stopChan = make(chan bool)
 	// 时间
 	var (
@@ -42,9 +39,45 @@ func ReceivingResults(concurrent uint64, ch <-chan *model.RequestResults, wg *sy
 		chanIDLen      int    // 并发数
 		chanIDs        = make(map[uint64]bool)
 		receivedBytes  int64
+		sentBytes      int64
 		mutex          = sync.RWMutex{}
+		latency        = NewHistogram()    // 请求响应时间分布
+		corrected      *Histogram          // 协调遗漏修正后的响应时间分布
+		coInterval     uint64              // 闭合模型 协调遗漏修正的期望请求间隔
+		endpointList   = newEndpoints()    // 分接口统计
+		phaseList      = newPhases()       // HTTP 请求各阶段耗时分布
+		errorList      = newErrorSamples() // 错误信息统计
+		codeList       = newCodes()        // 按错误码、成功/失败分组统计
+		intervals      []*Interval         // 每个统计周期的数据
 	)
-	statTime := uint64(time.Now().UnixNano())
+	if task.CoCorrect {
+		corrected = NewHistogram()
+	}
+	if !task.IsOpen() {
+		coInterval = uint64(task.CoInterval)
+	}
+	concurrent := task.Workers()
+	startTime := time.Now()
+	statTime := uint64(startTime.UnixNano())
+	// 预热阶段结束以后才开始统计
+	current := newIntervalStat(startTime)
+	aborts := newAbortCheck()
+	v := newView(task)
+	// nextInterval 结束一个统计周期 需要持有 mutex，返回的数据不再修改
+	nextInterval := func(now time.Time) *Interval {
+		workers := chanIDLen
+		if task.IsStaged() && !task.IsOpen() {
+			workers = status.GetWorkers()
+		}
+		interval := current.next(now, now.Sub(time.Unix(0, int64(statTime))), workers, status.GetDropped())
+		intervals = append(intervals, interval)
+		return interval
+	}
+	// publish 输出统计周期的数据 写文件、推送可能很慢，在 mutex 之外调用，不阻塞接收结果
+	publish := func(interval *Interval) {
+		exportInterval(interval)
+		v.interval(interval)
+	}
 	// 错误码/错误个数
 	var errCode = &sync.Map{}
 	// 定时输出一次计算结果
@@ -53,20 +86,47 @@ func ReceivingResults(concurrent uint64, ch <-chan *model.RequestResults, wg *sy
 		for {
 			select {
 			case <-ticker.C:
-				endTime := uint64(time.Now().UnixNano())
+				now := time.Now()
+				endTime := uint64(now.UnixNano())
 				mutex.Lock()
-				go calculateData(concurrent, processingTime, endTime-statTime, maxTime, minTime, successNum, failureNum,
-					chanIDLen, errCode, receivedBytes)
+				if warm.isWarmUp(now) {
+					warm.print(now)
+					mutex.Unlock()
+					continue
+				}
+				if end := warm.end(); statTime < uint64(end.UnixNano()) {
+					// 预热结束 开始统计
+					statTime = uint64(end.UnixNano())
+					current.start = end
+					v.header()
+					mutex.Unlock()
+					continue
+				}
+				snap := calculateData(task, status, processingTime, endTime-statTime, maxTime, minTime, successNum,
+					failureNum, chanIDLen, errCode, receivedBytes, sentBytes, latency.Percentiles(percentiles))
+				if reason := aborts.check(current.result(now)); reason != "" {
+					status.Abort(reason)
+				}
+				interval := nextInterval(now)
 				mutex.Unlock()
+				// 同步输出 结束时等待本次输出完成以后再输出结果，不会在结果之后输出
+				v.update(snap)
+				publish(interval)
 			case <-stopChan:
 				// 处理完成
 				return
 			}
 		}
 	}()
-	header()
+	if !warm.enabled() {
+		v.header()
+	}
 	for data := range ch {
 		mutex.Lock()
+		if data.WarmUp {
+			mutex.Unlock()
+			continue
+		}
 		// fmt.Println("处理一条数据", data.ID, data.Time, data.IsSucceed, data.ErrCode)
 		processingTime = processingTime + data.Time
 		if maxTime <= data.Time {
@@ -91,19 +151,74 @@ func ReceivingResults(concurrent uint64, ch <-chan *model.RequestResults, wg *sy
 			errCode.Store(data.ErrCode, 1)
 		}
 		receivedBytes += data.ReceivedBytes
+		sentBytes += data.SentBytes
 		if _, ok := chanIDs[data.ChanID]; !ok {
 			chanIDs[data.ChanID] = true
 			chanIDLen = len(chanIDs)
 		}
-		requestTimeList = append(requestTimeList, data.Time)
+		latency.Record(data.Time)
+		endpointList.add(data)
+		phaseList.add(data)
+		errorList.add(data)
+		codeList.add(data)
+		current.add(data)
+		if task.CoCorrect {
+			correctedTimes(data, coInterval, corrected.Record)
+		}
 		mutex.Unlock()
 	}
 	// 数据全部接受完成，停止定时输出统计数据
 	stopChan <- true
-	endTime := uint64(time.Now().UnixNano())
+	mutex.Lock()
+	defer mutex.Unlock()
+	if end := warm.finish(time.Now()); statTime < uint64(end.UnixNano()) {
+		statTime = uint64(end.UnixNano())
+		current.start = end
+		v.header()
+	}
+	now := time.Now()
+	// 最后一个不足一秒的统计周期
+	if current.successNum+current.failureNum > 0 {
+		publish(nextInterval(now))
+	}
+	endTime := uint64(now.UnixNano())
 	requestTime = endTime - statTime
-	calculateData(concurrent, processingTime, requestTime, maxTime, minTime, successNum, failureNum, chanIDLen, errCode,
-		receivedBytes)
+	snap := calculateData(task, status, processingTime, requestTime, maxTime, minTime, successNum, failureNum,
+		chanIDLen, errCode, receivedBytes, sentBytes, latency.Percentiles(percentiles))
+	v.update(snap)
+	v.close()
+	if reason := status.GetStopReason(); reason != "" {
+		fmt.Printf("\n 压测结束: %s \n", reason)
+	}
+	warmUpTime, warmUpNum, warmUpSuccess, warmUpFailure := warm.summary()
+	result = &Result{
+		Concurrency:    concurrent,
+		SuccessNum:     successNum,
+		FailureNum:     failureNum,
+		Dropped:        snap.dropped,
+		RequestTime:    requestTime,
+		ProcessingTime: processingTime,
+		QPS:            snap.qps,
+		MaxTime:        maxTime,
+		MinTime:        minTime,
+		ReceivedBytes:  receivedBytes,
+		SentBytes:      sentBytes,
+		ErrCode:        make(map[int]int),
+		WarmUpNum:      warmUpNum,
+		Endpoints:      endpointList.results(requestTime),
+		Phases:         phaseList.results(),
+		ErrorSamples:   errorList.results(),
+		Intervals:      intervals,
+		AbortReason:    status.GetAbortReason(),
+		latency:        latency,
+		corrected:      corrected,
+	}
+	errCode.Range(func(key, value interface{}) bool {
+		result.ErrCode[key.(int)] = value.(int)
+		return true
+	})
+	result.Codes, result.Outcomes = codeList.results()
+	result.Thresholds = checkThresholds(result, endpointList.find)
 
 	fmt.Printf("\n\n")
 	fmt.Println("*************************  结果 stat  ****************************")
@@ -112,94 +227,94 @@ func ReceivingResults(concurrent uint64, ch <-chan *model.RequestResults, wg *sy
 	fmt.Println("请求总数（并发数*请求数 -c * -n）:", successNum+failureNum, "总请求时间:",
 		fmt.Sprintf("%.3f", float64(requestTime)/1e9),
 		"秒", "successNum:", successNum, "failureNum:", failureNum)
-	printTop(requestTimeList)
+	if warm.enabled() {
+		fmt.Println("预热(不计入统计) 时长:", fmt.Sprintf("%.3f", warmUpTime.Seconds()), "秒",
+			"请求数:", warmUpNum, "successNum:", warmUpSuccess, "failureNum:", warmUpFailure)
+	}
+	if result.AbortReason != "" {
+		fmt.Println("提前结束:", result.AbortReason)
+	}
+	if task.IsOpen() {
+		fmt.Println("到达速率:", task.Rate, "/s 最大并发:", task.MaxInFlight, "丢弃请求数(dropped):",
+			status.GetDropped())
+		if status.GetDropped() > 0 {
+			fmt.Println("有请求因为没有空闲协程被丢弃，到达速率超出了最大并发能承受的范围，可以增大 -maxInFlight")
+		}
+	}
+	printTop(latency)
+	if task.CoCorrect {
+		fmt.Println("协调遗漏修正后(耗时从计划发起时间开始计算):")
+		printTop(corrected)
+	}
+	printEndpoints(result)
+	printCodes(result)
+	printPhases(result.Phases)
+	printErrors(result)
+	printThresholds(result.Thresholds)
 	fmt.Println("*************************  结果 end   ****************************")
 	fmt.Printf("\n\n")
+	return
 }
 
-// printTop 排序后计算 top 90 95 99
-func printTop(requestTimeList []uint64) {
-	if requestTimeList == nil {
+// printTop 输出设置的耗时百分位 纳秒=>毫秒
+func printTop(histogram *Histogram) {
+	if histogram == nil || histogram.Count() == 0 {
 		return
 	}
-	all := tools.MyUint64List{}
-	all = requestTimeList
-	sort.Sort(all)
-	fmt.Println("tp90:", fmt.Sprintf("%.3f", float64(all[int(float64(len(all))*0.90)]/1e6)))
-	fmt.Println("tp95:", fmt.Sprintf("%.3f", float64(all[int(float64(len(all))*0.95)]/1e6)))
-	fmt.Println("tp99:", fmt.Sprintf("%.3f", float64(all[int(float64(len(all))*0.99)]/1e6)))
+	values := histogram.Percentiles(percentiles)
+	for i, percent := range percentiles {
+		fmt.Println(PercentileName(percent)+":", fmt.Sprintf("%.3f", float64(values[i])/1e6))
+	}
 }
 
 // calculateData 计算数据
-func calculateData(concurrent, processingTime, requestTime, maxTime, minTime, successNum, failureNum uint64,
-	chanIDLen int, errCode *sync.Map, receivedBytes int64) {
+func calculateData(task *model.TaskForm, status *model.TaskStatus, processingTime, requestTime, maxTime, minTime,
+	successNum, failureNum uint64, chanIDLen int, errCode *sync.Map, receivedBytes, sentBytes int64,
+	latencyPercentiles []uint64) *snapshot {
+	concurrent := task.Workers()
 	if processingTime == 0 {
 		processingTime = 1
 	}
 	var (
-		qps              float64
-		averageTime      float64
-		maxTimeFloat     float64
-		minTimeFloat     float64
-		requestTimeFloat float64
+		qps         float64
+		averageTime float64
 	)
 	// 平均 QPS 成功数*总协程数/总耗时 (每秒)
 	if processingTime != 0 {
 		qps = float64(successNum*1e9*concurrent) / float64(processingTime)
 	}
+	// 开放模型、分阶段压测 协程数不固定，QPS 为 成功数/压测时长
+	if (task.IsOpen() || task.IsStaged()) && requestTime != 0 {
+		qps = float64(successNum*1e9) / float64(requestTime)
+	}
 	// 平均时长 总耗时/总请求数/并发数 纳秒=>毫秒
 	if successNum != 0 && concurrent != 0 {
 		averageTime = float64(processingTime) / float64(successNum*1e6)
 	}
+	// 闭合模型分阶段压测 显示当前运行的协程数
+	if task.IsStaged() && !task.IsOpen() {
+		chanIDLen = status.GetWorkers()
+	}
+	stage, target := status.GetStage()
 	// 纳秒=>毫秒
-	maxTimeFloat = float64(maxTime) / 1e6
-	minTimeFloat = float64(minTime) / 1e6
-	requestTimeFloat = float64(requestTime) / 1e9
-	// 打印的时长都为毫秒
-	table(successNum, failureNum, errCode, qps, averageTime, maxTimeFloat, minTimeFloat, requestTimeFloat, chanIDLen,
-		receivedBytes)
-}
-
-// header 打印表头信息
-func header() {
-	fmt.Printf("\n\n")
-	// 打印的时长都为毫秒 总请数
-	fmt.Println("─────┬───────┬───────┬───────┬────────┬────────┬────────┬────────┬────────┬────────┬────────")
-	fmt.Println(" 耗时│ 并发数│ 成功数│ 失败数│   qps  │最长耗时│最短耗时│平均耗时│下载字节│字节每秒│ 状态码")
-	fmt.Println("─────┼───────┼───────┼───────┼────────┼────────┼────────┼────────┼────────┼────────┼────────")
-	return
-}
-
-// table 打印表格
-func table(successNum, failureNum uint64, errCode *sync.Map,
-	qps, averageTime, maxTimeFloat, minTimeFloat, requestTimeFloat float64, chanIDLen int, receivedBytes int64) {
-	var (
-		speed int64
-	)
-	if requestTimeFloat > 0 {
-		speed = int64(float64(receivedBytes) / requestTimeFloat)
-	} else {
-		speed = 0
+	snap := &snapshot{
+		requestTime:   float64(requestTime) / 1e9,
+		chanIDLen:     chanIDLen,
+		successNum:    successNum,
+		failureNum:    failureNum,
+		dropped:       status.GetDropped(),
+		qps:           qps,
+		maxTime:       float64(maxTime) / 1e6,
+		minTime:       float64(minTime) / 1e6,
+		averageTime:   averageTime,
+		percentiles:   latencyPercentiles,
+		receivedBytes: receivedBytes,
+		sentBytes:     sentBytes,
+		errCode:       printMap(errCode),
+		stage:         stage,
+		target:        target,
 	}
-	var (
-		receivedBytesStr string
-		speedStr         string
-	)
-	// 判断获取下载字节长度是否是未知
-	if receivedBytes <= 0 {
-		receivedBytesStr = ""
-		speedStr = ""
-	} else {
-		receivedBytesStr = p.Sprintf("%d", receivedBytes)
-		speedStr = p.Sprintf("%d", speed)
-	}
-	// 打印的时长都为毫秒
-	result := fmt.Sprintf("%4.0fs│%7d│%7d│%7d│%8.2f│%8.2f│%8.2f│%8.2f│%8s│%8s│%v",
-		requestTimeFloat, chanIDLen, successNum, failureNum, qps, maxTimeFloat, minTimeFloat, averageTime,
-		receivedBytesStr, speedStr,
-		printMap(errCode))
-	fmt.Println(result)
-	return
+	return snap
 }
 
 // printMap 输出错误码、次数 节约字符(终端一行字符大小有限)
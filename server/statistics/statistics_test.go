@@ -11,6 +11,9 @@ import (
 	"reflect"
 	"sync"
 	"testing"
+	"time"
+
+	"goapistress/model"
 )
 
 // TestPrintMap
@@ -38,7 +41,7 @@ func TestPrintMap(t *testing.T) {
 
 func Test_printTop(t *testing.T) {
 	type args struct {
-		requestTimeList []uint64
+		histogram *Histogram
 	}
 	tests := []struct {
 		name string
@@ -47,19 +50,162 @@ func Test_printTop(t *testing.T) {
 		{
 			name: "nil",
 			args: args{
-				requestTimeList: nil,
+				histogram: nil,
 			},
 		},
 		{
 			name: "one data",
 			args: args{
-				requestTimeList: []uint64{1 * 1e6},
+				histogram: func() *Histogram {
+					h := NewHistogram()
+					h.Record(1 * 1e6)
+					return h
+				}(),
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			printTop(tt.args.requestTimeList)
+			printTop(tt.args.histogram)
 		})
 	}
 }
+
+func Test_correctedTimes(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     *model.RequestResults
+		interval uint64
+		want     []uint64
+	}{
+		{
+			name: "open delay",
+			data: &model.RequestResults{Time: 10, Delay: 5},
+			want: []uint64{15},
+		},
+		{
+			name:     "closed fast",
+			data:     &model.RequestResults{Time: 10},
+			interval: 10,
+			want:     []uint64{10},
+		},
+		{
+			name:     "closed stall",
+			data:     &model.RequestResults{Time: 45},
+			interval: 10,
+			want:     []uint64{45, 35, 25, 15},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []uint64
+			correctedTimes(tt.data, tt.interval, func(requestTime uint64) {
+				got = append(got, requestTime)
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("数据不一致 预期:%v 实际:%v", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_endpoints(t *testing.T) {
+	list := newEndpoints()
+	list.add(&model.RequestResults{Time: 3e6, IsSucceed: true, ErrCode: 200, Endpoint: "a"})
+	list.add(&model.RequestResults{Time: 5e6, IsSucceed: false, ErrCode: 500, Steps: []*model.RequestResults{
+		{Time: 2e6, IsSucceed: true, ErrCode: 200, Endpoint: "a"},
+		{Time: 3e6, IsSucceed: false, ErrCode: 500, Endpoint: "b"},
+	}})
+	results := list.results(1e9)
+	if len(results) != 2 {
+		t.Fatalf("数据不一致 预期:%v 实际:%v", 2, len(results))
+	}
+	a, b := results[0], results[1]
+	if a.Name != "a" || a.SuccessNum != 2 || a.QPS != 2 || a.MaxTime != 3e6 || a.MinTime != 2e6 {
+		t.Errorf("数据不一致 实际:%+v", a)
+	}
+	if b.Name != "b" || b.FailureNum != 1 || b.ErrCode[500] != 1 {
+		t.Errorf("数据不一致 实际:%+v", b)
+	}
+}
+
+func Test_phases(t *testing.T) {
+	list := newPhases()
+	list.add(&model.RequestResults{Phases: &model.Phases{DNS: 1e6, Connect: 2e6, TTFB: 3e6, Download: 1e5}})
+	list.add(&model.RequestResults{Phases: &model.Phases{TTFB: 5e6, Download: 2e5}})
+	list.add(&model.RequestResults{})
+	results := list.results()
+	want := map[string]uint64{"dns": 1, "connect": 1, "ttfb": 2, "download": 2}
+	if len(results) != len(want) {
+		t.Fatalf("数据不一致 预期:%v 实际:%v", want, results)
+	}
+	for name, count := range want {
+		if results[name].Count() != count {
+			t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, count, results[name].Count())
+		}
+	}
+}
+
+// Test_codes 测试按错误码、成功/失败分组统计耗时和响应大小
+func Test_codes(t *testing.T) {
+	list := newCodes()
+	list.add(&model.RequestResults{Time: 100e6, IsSucceed: true, ErrCode: 200, ReceivedBytes: 2000})
+	list.add(&model.RequestResults{Time: 300e6, IsSucceed: true, ErrCode: 200, ReceivedBytes: 4000})
+	list.add(&model.RequestResults{Time: 2e6, ErrCode: 500, ReceivedBytes: 10})
+	codes, outcomes := list.results()
+	if len(codes) != 2 || len(outcomes) != 2 {
+		t.Fatalf("数据不一致 预期:2,2 实际:%d,%d", len(codes), len(outcomes))
+	}
+	ok, failed := codes[0], codes[1]
+	if ok.Code != 200 || ok.Count != 2 || ok.MinBytes != 2000 || ok.MaxBytes != 4000 || ok.AverageBytes() != 3000 {
+		t.Errorf("数据不一致 实际:%+v", ok)
+	}
+	if failed.Code != 500 || failed.latency.Max() > 3e6 || outcomes[1].Name != outcomeFailure {
+		t.Errorf("数据不一致 实际:%+v %+v", failed, outcomes[1])
+	}
+	if max := outcomes[0].latency.Max(); max < 299e6 {
+		t.Errorf("数据不一致 预期:%v 实际:%v", uint64(300e6), max)
+	}
+}
+
+// Test_sparkline 测试面板的迷你折线图和按显示宽度截断
+func Test_sparkline(t *testing.T) {
+	tt := map[string]struct {
+		values []float64
+		width  int
+		want   string
+	}{
+		"empty":  {values: nil, width: 10, want: ""},
+		"zero":   {values: []float64{0, 0}, width: 10, want: "▁▁"},
+		"scale":  {values: []float64{0, 50, 100}, width: 10, want: "▁▄█"},
+		"recent": {values: []float64{100, 0, 100}, width: 2, want: "▁█"},
+	}
+	for name, value := range tt {
+		if got := sparkline(value.values, value.width); got != value.want {
+			t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, value.want, got)
+		}
+	}
+	if got := truncate("成功数:10", 7); got != "成功数:" {
+		t.Errorf("数据不一致 预期:%v 实际:%v", "成功数:", got)
+	}
+	if got := truncate(sparkline([]float64{1, 2, 3}, 3), 2); got != "▃▅" {
+		t.Errorf("数据不一致 预期:%v 实际:%v", "▃▅", got)
+	}
+}
+
+// TestWarmUpMark 测试按请求数预热 预热结束以后的请求不再标记
+func TestWarmUpMark(t *testing.T) {
+	warm := NewWarmUp(&model.TaskForm{WarmUpNumber: 2}, time.Now())
+	var marks []bool
+	for i := 0; i < 4; i++ {
+		data := &model.RequestResults{IsSucceed: i != 0}
+		warm.Mark(data)
+		marks = append(marks, data.WarmUp)
+	}
+	if want := []bool{true, true, false, false}; !reflect.DeepEqual(marks, want) {
+		t.Errorf("数据不一致 预期:%v 实际:%v", want, marks)
+	}
+	if _, total, successNum, failureNum := warm.summary(); total != 2 || successNum != 1 || failureNum != 1 {
+		t.Errorf("数据不一致 预期:2 1 1 实际:%d %d %d", total, successNum, failureNum)
+	}
+}
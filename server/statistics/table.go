@@ -0,0 +1,153 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"fmt"
+	"strings"
+
+	"goapistress/model"
+)
+
+// snapshot 某一时刻的统计数据 时长都为毫秒
+type snapshot struct {
+	requestTime   float64  // 压测耗时 秒
+	chanIDLen     int      // 并发数
+	successNum    uint64   // 成功数
+	failureNum    uint64   // 失败数
+	dropped       uint64   // 开放模型丢弃数
+	qps           float64  // qps
+	maxTime       float64  // 最长耗时
+	minTime       float64  // 最短耗时
+	averageTime   float64  // 平均耗时
+	percentiles   []uint64 // 耗时百分位 纳秒 与 Percentiles 一一对应
+	receivedBytes int64    // 下载字节
+	sentBytes     int64    // 上传字节
+	errCode       string   // 状态码:次数
+	stage         int      // 分阶段压测 当前阶段
+	target        float64  // 分阶段压测 当前目标值
+}
+
+// speed 下载字节每秒
+func (s *snapshot) speed() int64 {
+	if s.requestTime > 0 {
+		return int64(float64(s.receivedBytes) / s.requestTime)
+	}
+	return 0
+}
+
+// sendSpeed 上传字节每秒
+func (s *snapshot) sendSpeed() int64 {
+	if s.requestTime > 0 {
+		return int64(float64(s.sentBytes) / s.requestTime)
+	}
+	return 0
+}
+
+// column 表格列
+type column struct {
+	title string                   // 标题，已按显示宽度对齐
+	width int                      // 显示宽度
+	value func(s *snapshot) string // 单元格内容
+}
+
+// columns 表格的列 随压测模式增加
+func columns(task *model.TaskForm) (cols []column) {
+	cols = append(cols, column{" 耗时", 5, func(s *snapshot) string {
+		return fmt.Sprintf("%4.0fs", s.requestTime)
+	}})
+	if task.IsStaged() {
+		cols = append(cols, column{" 阶段", 5, func(s *snapshot) string {
+			return fmt.Sprintf("%5d", s.stage+1)
+		}}, column{"   目标", 7, func(s *snapshot) string {
+			if task.IsOpen() {
+				return fmt.Sprintf("%7.1f", s.target)
+			}
+			return fmt.Sprintf("%7.0f", s.target)
+		}})
+	}
+	cols = append(cols, column{" 并发数", 7, func(s *snapshot) string {
+		return fmt.Sprintf("%7d", s.chanIDLen)
+	}}, column{" 成功数", 7, func(s *snapshot) string {
+		return fmt.Sprintf("%7d", s.successNum)
+	}}, column{" 失败数", 7, func(s *snapshot) string {
+		return fmt.Sprintf("%7d", s.failureNum)
+	}})
+	if task.IsOpen() {
+		cols = append(cols, column{" 丢弃数", 7, func(s *snapshot) string {
+			return fmt.Sprintf("%7d", s.dropped)
+		}})
+	}
+	cols = append(cols, column{"   qps  ", 8, func(s *snapshot) string {
+		return fmt.Sprintf("%8.2f", s.qps)
+	}}, column{"最长耗时", 8, func(s *snapshot) string {
+		return fmt.Sprintf("%8.2f", s.maxTime)
+	}}, column{"最短耗时", 8, func(s *snapshot) string {
+		return fmt.Sprintf("%8.2f", s.minTime)
+	}}, column{"平均耗时", 8, func(s *snapshot) string {
+		return fmt.Sprintf("%8.2f", s.averageTime)
+	}})
+	for i, percent := range percentiles {
+		i := i
+		cols = append(cols, column{fmt.Sprintf("%8s", PercentileName(percent)), 8, func(s *snapshot) string {
+			return fmt.Sprintf("%8.2f", float64(s.percentiles[i])/1e6)
+		}})
+	}
+	cols = append(cols, column{"下载字节", 8, func(s *snapshot) string {
+		// 判断获取下载字节长度是否是未知
+		if s.receivedBytes <= 0 {
+			return fmt.Sprintf("%8s", "")
+		}
+		return fmt.Sprintf("%8s", p.Sprintf("%d", s.receivedBytes))
+	}}, column{"字节每秒", 8, func(s *snapshot) string {
+		if s.receivedBytes <= 0 {
+			return fmt.Sprintf("%8s", "")
+		}
+		return fmt.Sprintf("%8s", p.Sprintf("%d", s.speed()))
+	}}, column{"上传字节", 8, func(s *snapshot) string {
+		if s.sentBytes <= 0 {
+			return fmt.Sprintf("%8s", "")
+		}
+		return fmt.Sprintf("%8s", p.Sprintf("%d", s.sentBytes))
+	}}, column{"上传每秒", 8, func(s *snapshot) string {
+		if s.sentBytes <= 0 {
+			return fmt.Sprintf("%8s", "")
+		}
+		return fmt.Sprintf("%8s", p.Sprintf("%d", s.sendSpeed()))
+	}}, column{" 状态码", 8, func(s *snapshot) string {
+		return s.errCode
+	}})
+	return
+}
+
+// header 打印表头信息
+func header(task *model.TaskForm) {
+	var (
+		cols   = columns(task)
+		lines  = make([]string, len(cols))
+		titles = make([]string, len(cols))
+	)
+	for i, col := range cols {
+		lines[i] = strings.Repeat("─", col.width)
+		titles[i] = col.title
+	}
+	fmt.Printf("\n\n")
+	// 打印的时长都为毫秒 总请数
+	fmt.Println(strings.Join(lines, "┬"))
+	fmt.Println(strings.Join(titles, "│"))
+	fmt.Println(strings.Join(lines, "┼"))
+	return
+}
+
+// table 打印表格
+func table(task *model.TaskForm, snap *snapshot) {
+	var (
+		cols   = columns(task)
+		values = make([]string, len(cols))
+	)
+	for i, col := range cols {
+		values[i] = col.value(snap)
+	}
+	// 打印的时长都为毫秒
+	fmt.Println(strings.Join(values, "│"))
+	return
+}
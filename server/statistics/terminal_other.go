@@ -0,0 +1,9 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+
+// Package statistics 统计数据
+package statistics
+
+// terminalSize 标准输出的终端大小 不支持的系统都按不是终端处理，使用逐行输出的表格
+func terminalSize() (width, height int, ok bool) {
+	return 0, 0, false
+}
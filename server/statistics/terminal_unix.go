@@ -0,0 +1,19 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+// Package statistics 统计数据
+package statistics
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminalSize 标准输出的终端大小 标准输出不是终端时 ok 为 false
+func terminalSize() (width, height int, ok bool) {
+	size, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil || size.Col == 0 || size.Row == 0 {
+		return 0, 0, false
+	}
+	return int(size.Col), int(size.Row), true
+}
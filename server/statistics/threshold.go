@@ -0,0 +1,217 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 判定指标
+const (
+	metricAvg       = "avg"        // 平均耗时
+	metricMax       = "max"        // 最长耗时
+	metricMin       = "min"        // 最短耗时
+	metricErrorRate = "error_rate" // 错误率 百分比
+	metricQPS       = "qps"        // qps
+)
+
+// Threshold 判定条件 示例: p99<300ms avg<=100 error_rate<0.1% qps>1000
+// 耗时的单位为毫秒，错误率为百分比，指标后加 [接口名称] 时只判定该接口 示例: p99[step1]<300ms
+type Threshold struct {
+	Expr     string  // 表达式
+	Metric   string  // 指标 p99 avg max min error_rate qps
+	Endpoint string  // 接口名称 为空时判定所有请求
+	Op       string  // 比较符 < <= > >=
+	Value    float64 // 阈值
+}
+
+// ParseThresholds 解析判定条件，多个条件以逗号分隔
+func ParseThresholds(str string) (thresholds []*Threshold, err error) {
+	for _, expr := range strings.Split(str, ",") {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+		var threshold *Threshold
+		threshold, err = parseThreshold(expr)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return
+}
+
+// parseThreshold 解析单个判定条件
+func parseThreshold(expr string) (threshold *Threshold, err error) {
+	// 接口名称中可能有比较符，从接口名称之后开始查找
+	start := strings.LastIndex(expr, "]")
+	index := strings.IndexAny(expr[start+1:], "<>")
+	if index < 0 {
+		return nil, fmt.Errorf("判定条件不合法:%s 示例:p99<300ms", expr)
+	}
+	index = index + start + 1
+	metric, endpoint := strings.TrimSpace(expr[:index]), ""
+	if open := strings.Index(metric, "["); open >= 0 {
+		if !strings.HasSuffix(metric, "]") {
+			return nil, fmt.Errorf("判定条件不合法:%s 示例:p99[step1]<300ms", expr)
+		}
+		metric, endpoint = strings.TrimSpace(metric[:open]), metric[open+1:len(metric)-1]
+	}
+	if metric == "" {
+		return nil, fmt.Errorf("判定条件不合法:%s 示例:p99<300ms", expr)
+	}
+	threshold = &Threshold{
+		Expr:     expr,
+		Metric:   strings.ToLower(metric),
+		Endpoint: endpoint,
+		Op:       expr[index : index+1],
+	}
+	value := expr[index+1:]
+	if strings.HasPrefix(value, "=") {
+		threshold.Op = threshold.Op + "="
+		value = value[1:]
+	}
+	value = strings.TrimSpace(value)
+	switch {
+	case threshold.isLatency():
+		threshold.Value, err = parseMillisecond(value)
+	case threshold.Metric == metricErrorRate:
+		threshold.Value, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	case threshold.Metric == metricQPS:
+		threshold.Value, err = strconv.ParseFloat(value, 64)
+	default:
+		return nil, fmt.Errorf("判定指标不支持:%s 支持:pN avg max min error_rate qps", threshold.Metric)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("判定阈值不合法:%s %w", expr, err)
+	}
+	return
+}
+
+// parseMillisecond 解析耗时 支持 300ms 1.5s，不带单位为毫秒
+func parseMillisecond(value string) (float64, error) {
+	if ms, err := strconv.ParseFloat(value, 64); err == nil {
+		return ms, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return float64(d) / 1e6, nil
+}
+
+// isLatency 是否为耗时指标
+func (t *Threshold) isLatency() bool {
+	switch t.Metric {
+	case metricAvg, metricMax, metricMin:
+		return true
+	}
+	_, ok := t.percent()
+	return ok
+}
+
+// percent 百分位指标 p99 p99.9
+func (t *Threshold) percent() (percent float64, ok bool) {
+	if !strings.HasPrefix(t.Metric, "p") {
+		return
+	}
+	percent, err := strconv.ParseFloat(t.Metric[1:], 64)
+	if err != nil || percent <= 0 || percent > 100 {
+		return 0, false
+	}
+	return percent, true
+}
+
+// Actual 压测结果中该指标的实际值
+func (t *Threshold) Actual(result *Result) float64 {
+	switch t.Metric {
+	case metricAvg:
+		return float64(result.AverageTime()) / 1e6
+	case metricMax:
+		return float64(result.MaxTime) / 1e6
+	case metricMin:
+		return float64(result.MinTime) / 1e6
+	case metricErrorRate:
+		return result.ErrorRate()
+	case metricQPS:
+		return result.QPS
+	}
+	percent, _ := t.percent()
+	return float64(result.Percentile(percent)) / 1e6
+}
+
+// Check 判定压测结果是否满足条件
+func (t *Threshold) Check(result *Result) (actual float64, ok bool) {
+	actual = t.Actual(result)
+	switch t.Op {
+	case "<":
+		ok = actual < t.Value
+	case "<=":
+		ok = actual <= t.Value
+	case ">":
+		ok = actual > t.Value
+	case ">=":
+		ok = actual >= t.Value
+	}
+	return
+}
+
+// ThresholdResult 判定结果
+type ThresholdResult struct {
+	Threshold *Threshold // 判定条件
+	Actual    float64    // 实际值
+	Passed    bool       // 是否满足条件
+	Missing   bool       // 压测结果中没有该接口
+}
+
+// thresholds 压测结束时的判定条件
+var thresholds []*Threshold
+
+// SetThresholds 设置压测结束时的判定条件 多个条件以逗号分隔
+func SetThresholds(str string) (err error) {
+	list, err := ParseThresholds(str)
+	if err != nil {
+		return
+	}
+	thresholds = list
+	return
+}
+
+// checkThresholds 判定压测结果 find 按名称查找接口的统计结果
+func checkThresholds(result *Result, find func(name string) *Result) (list []*ThresholdResult) {
+	for _, threshold := range thresholds {
+		target := result
+		if threshold.Endpoint != "" {
+			target = find(threshold.Endpoint)
+		}
+		if target == nil {
+			list = append(list, &ThresholdResult{Threshold: threshold, Missing: true})
+			continue
+		}
+		actual, ok := threshold.Check(target)
+		list = append(list, &ThresholdResult{Threshold: threshold, Actual: actual, Passed: ok})
+	}
+	return
+}
+
+// printThresholds 输出判定结果
+func printThresholds(list []*ThresholdResult) {
+	if len(list) == 0 {
+		return
+	}
+	fmt.Println("判定条件:")
+	fmt.Println(" 结果│      实际值│ 条件")
+	for _, item := range list {
+		state, actual, expr := "PASS", fmt.Sprintf("%11.3f", item.Actual), item.Threshold.Expr
+		if !item.Passed {
+			state = "FAIL"
+		}
+		if item.Missing {
+			actual, expr = fmt.Sprintf("%11s", "-"), expr+" 压测结果中没有该接口"
+		}
+		fmt.Printf(" %s│%s│ %s\n", state, actual, expr)
+	}
+}
@@ -0,0 +1,150 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseThresholds 测试判定条件解析
+func TestParseThresholds(t *testing.T) {
+	tt := map[string]struct {
+		str      string
+		metric   string
+		endpoint string
+		op       string
+		value    float64
+		isErr    bool
+	}{
+		"percentile": {str: "p99<300ms", metric: "p99", op: "<", value: 300},
+		"second":     {str: "p99.9 <= 1.5s", metric: "p99.9", op: "<=", value: 1500},
+		"avg":        {str: "avg<100", metric: "avg", op: "<", value: 100},
+		"errorRate":  {str: "error_rate<0.1%", metric: "error_rate", op: "<", value: 0.1},
+		"qps":        {str: "qps>=1000", metric: "qps", op: ">=", value: 1000},
+		"endpoint":   {str: "p99[step1]<200ms", metric: "p99", endpoint: "step1", op: "<", value: 200},
+		"endpointOp": {str: "qps[GET /a<b>]>10", metric: "qps", endpoint: "GET /a<b>", op: ">", value: 10},
+		"noOp":       {str: "p99", isErr: true},
+		"noMetric":   {str: "[step1]<1s", isErr: true},
+		"badBracket": {str: "p99[step1<1s", isErr: true},
+		"badMetric":  {str: "p101<1s", isErr: true},
+		"badValue":   {str: "qps>abc", isErr: true},
+	}
+	for name, value := range tt {
+		thresholds, err := ParseThresholds(value.str)
+		if (err != nil) != value.isErr {
+			t.Errorf("%s 错误不一致 预期:%v 实际:%v", name, value.isErr, err)
+			continue
+		}
+		if value.isErr {
+			continue
+		}
+		threshold := thresholds[0]
+		if threshold.Metric != value.metric || threshold.Op != value.op || threshold.Value != value.value ||
+			threshold.Endpoint != value.endpoint {
+			t.Errorf("%s 数据不一致 预期:%s[%s]%s%v 实际:%s[%s]%s%v", name, value.metric, value.endpoint, value.op,
+				value.value, threshold.Metric, threshold.Endpoint, threshold.Op, threshold.Value)
+		}
+	}
+}
+
+// TestThresholdCheck 测试判定
+func TestThresholdCheck(t *testing.T) {
+	result := &Result{
+		SuccessNum: 99,
+		FailureNum: 1,
+		QPS:        500,
+		latency:    NewHistogram(),
+	}
+	for i := uint64(1); i <= 100; i++ {
+		result.latency.Record(i * 1e6)
+	}
+	tt := map[string]bool{
+		"p99<300ms":      true,
+		"p50<=50":        false,
+		"error_rate<1%":  false,
+		"error_rate<=1%": true,
+		"qps>1000":       false,
+	}
+	for str, ok := range tt {
+		thresholds, err := ParseThresholds(str)
+		if err != nil {
+			t.Fatalf("%s 解析失败:%v", str, err)
+		}
+		if actual, pass := thresholds[0].Check(result); pass != ok {
+			t.Errorf("%s 判定不一致 预期:%v 实际:%v(%v)", str, ok, pass, actual)
+		}
+	}
+}
+
+// TestCheckThresholds 测试压测结束时按整体、接口判定
+func TestCheckThresholds(t *testing.T) {
+	endpoint := &Result{Name: "step1", SuccessNum: 1, FailureNum: 1}
+	result := &Result{SuccessNum: 9, FailureNum: 1}
+	find := func(name string) *Result {
+		if name == endpoint.Name {
+			return endpoint
+		}
+		return nil
+	}
+	tt := map[string]bool{
+		"":                                      true,
+		"error_rate<=10%":                       true,
+		"error_rate<=10%,error_rate[step1]<10%": false,
+		"error_rate[step2]<10%":                 false,
+	}
+	for str, ok := range tt {
+		if err := SetThresholds(str); err != nil {
+			t.Fatalf("%s 解析失败:%v", str, err)
+		}
+		result.Thresholds = checkThresholds(result, find)
+		if result.Passed() != ok {
+			t.Errorf("%s 判定不一致 预期:%v 实际:%v", str, ok, result.Passed())
+		}
+	}
+	thresholds = nil
+	if (&Result{FailureNum: 1}).Passed() {
+		t.Errorf("数据不一致 预期:%v 实际:%v", false, true)
+	}
+}
+
+// TestAbortCheck 测试提前结束条件连续满足指定时长后结束
+func TestAbortCheck(t *testing.T) {
+	for _, expr := range []string{
+		"error_rate>50%:abc",   // 持续时长不合法
+		"error_rate>50%:-10s",  // 持续时长为负数
+		"p95[step1]>5s:30s",    // 不支持按接口判定
+		"p95>5s:30s:10s",       // 冒号过多
+		"error_rate>50%:1:10s", // 值中有冒号
+		":10s",                 // 没有条件
+	} {
+		if _, err := ParseAbortConditions(expr); err == nil {
+			t.Errorf("%s 错误不一致 预期:%v 实际:%v", expr, true, err)
+		}
+	}
+	if err := SetAbortConditions("error_rate>50%:3s, p95>5s"); err != nil {
+		t.Fatalf("解析失败:%v", err)
+	}
+	defer func() {
+		abortConditions = nil
+	}()
+	failed := &Result{FailureNum: 10, latency: NewHistogram()}
+	passed := &Result{SuccessNum: 10, latency: NewHistogram()}
+	slow := &Result{SuccessNum: 1, latency: NewHistogram()}
+	slow.latency.Record(uint64(6 * time.Second))
+	empty := &Result{latency: NewHistogram()}
+	check := newAbortCheck()
+	for i, value := range []struct {
+		result *Result
+		abort  bool
+	}{
+		{failed, false}, {failed, false}, {passed, false}, {failed, false}, {failed, false}, {failed, true},
+		{slow, true},
+		// 没有完成请求的周期 既不计数也不重新计时
+		{passed, false}, {failed, false}, {empty, false}, {empty, false}, {failed, false}, {failed, true},
+		{empty, false},
+	} {
+		if reason := check.check(value.result); (reason != "") != value.abort {
+			t.Errorf("第%d个周期 数据不一致 预期:%v 实际:%v", i+1, value.abort, reason)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeSeriesWriter 按统计周期导出数据到文件
+type timeSeriesWriter struct {
+	file   *os.File
+	csv    *csv.Writer   // CSV 格式
+	json   *json.Encoder // JSON Lines 格式
+	header bool          // CSV 是否已写表头
+}
+
+// OpenTimeSeries 打开时间序列导出文件，每个统计周期(1秒)写一行
+// 扩展名为 .csv 时导出 CSV，.jsonl .json .ndjson 导出 JSON Lines
+func OpenTimeSeries(path string) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".csv" && ext != ".jsonl" && ext != ".json" && ext != ".ndjson" {
+		return fmt.Errorf("时间序列导出文件格式不支持:%s 支持:.csv .jsonl", path)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := &timeSeriesWriter{file: file}
+	if ext == ".csv" {
+		writer.csv = csv.NewWriter(file)
+	} else {
+		writer.json = json.NewEncoder(file)
+	}
+	RegisterIntervalSink(writer)
+	return nil
+}
+
+// Close 写入缓冲中的数据并关闭导出文件
+func (w *timeSeriesWriter) Close() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			_ = w.file.Close()
+			return err
+		}
+	}
+	return w.file.Close()
+}
+
+// Write 写入一行
+func (w *timeSeriesWriter) Write(interval *Interval) error {
+	if w.json != nil {
+		return w.json.Encode(interval)
+	}
+	if !w.header {
+		titles := []string{"time", "elapsed", "workers", "success", "failure", "dropped", "qps", "avg_ms", "max_ms"}
+		for _, percent := range percentiles {
+			titles = append(titles, PercentileName(percent)+"_ms")
+		}
+		titles = append(titles, "received_bytes", "sent_bytes", "err_code")
+		if err := w.csv.Write(titles); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	record := []string{
+		interval.Time.Format(time.RFC3339Nano),
+		strconv.FormatFloat(interval.Elapsed, 'f', 3, 64),
+		strconv.Itoa(interval.Workers),
+		strconv.FormatUint(interval.SuccessNum, 10),
+		strconv.FormatUint(interval.FailureNum, 10),
+		strconv.FormatUint(interval.Dropped, 10),
+		strconv.FormatFloat(interval.QPS, 'f', 2, 64),
+		strconv.FormatFloat(interval.AverageTime, 'f', 3, 64),
+		strconv.FormatFloat(interval.MaxTime, 'f', 3, 64),
+	}
+	for _, percent := range percentiles {
+		record = append(record, strconv.FormatFloat(interval.Percentiles[PercentileName(percent)], 'f', 3, 64))
+	}
+	record = append(record, strconv.FormatInt(interval.ReceivedBytes, 10), strconv.FormatInt(interval.SentBytes, 10),
+		errCodeString(interval.ErrCode))
+	if err := w.csv.Write(record); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
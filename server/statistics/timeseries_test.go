@@ -0,0 +1,64 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"goapistress/model"
+)
+
+// TestTimeSeries 测试时间序列导出 表头、每行的格式、没有请求的统计周期，关闭时写入全部数据
+func TestTimeSeries(t *testing.T) {
+	start := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	stat := newIntervalStat(start)
+	stat.add(&model.RequestResults{Time: 2e6, IsSucceed: true, ErrCode: 200, ReceivedBytes: 100, SentBytes: 10})
+	stat.add(&model.RequestResults{Time: 4e6, ErrCode: 500, ReceivedBytes: 20})
+	first := stat.next(start.Add(time.Second), time.Second, 2, 1)
+	// 没有请求完成的统计周期
+	empty := stat.next(start.Add(2*time.Second), 2*time.Second, 2, 1)
+	tt := map[string]struct {
+		file  string
+		lines []string
+	}{
+		"csv": {file: "series.csv", lines: []string{
+			"time,elapsed,workers,success,failure,dropped,qps,avg_ms,max_ms,tp90_ms,tp95_ms,tp99_ms,received_bytes," +
+				"sent_bytes,err_code",
+			"2022-01-02T03:04:06Z,1.000,2,1,1,1,1.00,3.000,4.000,4.000,4.000,4.000,120,10,200:1;500:1",
+			"2022-01-02T03:04:07Z,2.000,2,0,0,0,0.00,0.000,0.000,0.000,0.000,0.000,0,0,",
+		}},
+		"jsonl": {file: "series.jsonl", lines: []string{
+			`{"time":"2022-01-02T03:04:06Z","elapsed":1,"workers":2,"success":1,"failure":1,"dropped":1,"qps":1,` +
+				`"avg_ms":3,"max_ms":4,"percentiles_ms":{"tp90":4,"tp95":4,"tp99":4},"received_bytes":120,` +
+				`"sent_bytes":10,"err_code":{"200":1,"500":1}}`,
+			`{"time":"2022-01-02T03:04:07Z","elapsed":2,"workers":2,"success":0,"failure":0,"dropped":0,"qps":0,` +
+				`"avg_ms":0,"max_ms":0,"percentiles_ms":{"tp90":0,"tp95":0,"tp99":0},"received_bytes":0,` +
+				`"sent_bytes":0,"err_code":{}}`,
+		}},
+	}
+	for name, value := range tt {
+		path := filepath.Join(t.TempDir(), value.file)
+		if err := OpenTimeSeries(path); err != nil {
+			t.Fatalf("%s 打开失败:%v", name, err)
+		}
+		exportInterval(first)
+		exportInterval(empty)
+		if err := CloseIntervalSinks(); err != nil {
+			t.Fatalf("%s 关闭失败:%v", name, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.Join(value.lines, "\n")
+		if got := strings.TrimSpace(string(data)); got != want {
+			t.Errorf("%s 数据不一致 预期:%v 实际:%v", name, want, got)
+		}
+	}
+	if err := OpenTimeSeries(filepath.Join(t.TempDir(), "series.txt")); err == nil {
+		t.Errorf("数据不一致 预期:格式不支持 实际:%v", err)
+	}
+}
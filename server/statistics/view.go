@@ -0,0 +1,59 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"goapistress/model"
+)
+
+// view 压测过程中的实时输出 默认为逐行输出的表格，开启面板并且标准输出是终端时为全屏面板
+type view interface {
+	// header 开始统计(预热结束)
+	header()
+	// update 每秒的累计数据
+	update(snap *snapshot)
+	// interval 每个统计周期的数据
+	interval(interval *Interval)
+	// close 压测结束 在输出压测结果之前调用
+	close()
+}
+
+// dashboard 是否开启全屏面板
+var dashboard bool
+
+// SetDashboard 开启全屏面板 标准输出不是终端时仍使用逐行输出的表格
+func SetDashboard(enabled bool) {
+	dashboard = enabled
+}
+
+// newView 压测过程中的实时输出
+func newView(task *model.TaskForm) view {
+	if dashboard {
+		if width, height, ok := terminalSize(); ok {
+			return newDashboardView(task, width, height)
+		}
+	}
+	return &tableView{task: task}
+}
+
+// tableView 逐行输出的表格
+type tableView struct {
+	task *model.TaskForm
+}
+
+// header 打印表头
+func (v *tableView) header() {
+	header(v.task)
+}
+
+// update 打印一行
+func (v *tableView) update(snap *snapshot) {
+	table(v.task, snap)
+}
+
+// interval 表格只输出累计数据
+func (v *tableView) interval(*Interval) {
+}
+
+// close 表格不需要恢复终端
+func (v *tableView) close() {
+}
@@ -0,0 +1,115 @@
+// Package statistics 统计数据
+package statistics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"goapistress/model"
+)
+
+// WarmUp 预热阶段 预热期间的请求照常发送，结果单独统计，不计入 qps、耗时和百分位
+// 按时长或请求数预热，同时设置时先到先结束
+// 在交给观察者之前由 Mark 标记请求结果，统计和观察者都按 RequestResults.WarmUp 区分
+type WarmUp struct {
+	mutex      sync.Mutex
+	duration   time.Duration // 预热时长
+	number     uint64        // 预热请求数
+	startTime  time.Time     // 开始时间
+	endTime    time.Time     // 结束时间
+	done       bool          // 预热是否结束
+	successNum uint64        // 预热成功数
+	failureNum uint64        // 预热失败数
+}
+
+// NewWarmUp 预热阶段 从 startTime 开始
+func NewWarmUp(task *model.TaskForm, startTime time.Time) *WarmUp {
+	w := &WarmUp{
+		duration:  task.WarmUp,
+		number:    task.WarmUpNumber,
+		startTime: startTime,
+	}
+	if w.duration <= 0 && w.number == 0 {
+		w.done = true
+		w.endTime = startTime
+	}
+	return w
+}
+
+// Mark 标记请求结果是否属于预热阶段 并统计预热阶段的请求结果
+func (w *WarmUp) Mark(data *model.RequestResults) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	data.WarmUp = w.check(time.Now())
+	if !data.WarmUp {
+		return
+	}
+	if data.IsSucceed {
+		w.successNum = w.successNum + 1
+	} else {
+		w.failureNum = w.failureNum + 1
+	}
+}
+
+// isWarmUp now 时刻是否处于预热阶段，预热结束时记录结束时间
+func (w *WarmUp) isWarmUp(now time.Time) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.check(now)
+}
+
+// check now 时刻是否处于预热阶段 调用方加锁
+func (w *WarmUp) check(now time.Time) bool {
+	if w.done {
+		return false
+	}
+	if (w.duration > 0 && now.Sub(w.startTime) >= w.duration) || (w.number > 0 && w.total() >= w.number) {
+		w.done = true
+		w.endTime = now
+		return false
+	}
+	return true
+}
+
+// finish 压测结束 预热未结束时在 now 时刻结束，返回结束时间
+func (w *WarmUp) finish(now time.Time) time.Time {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if !w.done {
+		w.done = true
+		w.endTime = now
+	}
+	return w.endTime
+}
+
+// end 预热结束时间 预热未结束时为零值
+func (w *WarmUp) end() time.Time {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.endTime
+}
+
+// total 预热请求数 调用方加锁
+func (w *WarmUp) total() uint64 {
+	return w.successNum + w.failureNum
+}
+
+// enabled 是否设置了预热
+func (w *WarmUp) enabled() bool {
+	return w.duration > 0 || w.number > 0
+}
+
+// print 打印预热进度
+func (w *WarmUp) print(now time.Time) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	fmt.Printf("%4.0fs│ 预热中 成功数:%d 失败数:%d \n", now.Sub(w.startTime).Seconds(), w.successNum, w.failureNum)
+}
+
+// summary 预热结果 时长、请求数、成功数、失败数
+func (w *WarmUp) summary() (duration time.Duration, total, successNum, failureNum uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.endTime.Sub(w.startTime), w.total(), w.successNum, w.failureNum
+}
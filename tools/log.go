@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// quiet 是否不输出压测过程中的日志 全屏面板运行时为1
+var quiet int32
+
+// SetQuiet 设置是否不输出压测过程中的日志
+// 全屏面板运行时直接输出会破坏面板，失败原因已记录在请求结果中(错误分类、错误信息)
+func SetQuiet(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&quiet, value)
+}
+
+// Println 输出压测过程中的日志 全屏面板运行时不输出
+func Println(a ...interface{}) {
+	if atomic.LoadInt32(&quiet) == 0 {
+		fmt.Println(a...)
+	}
+}
+
+// Errorln 输出压测过程中的错误到标准错误 全屏面板运行时不输出
+func Errorln(a ...interface{}) {
+	if atomic.LoadInt32(&quiet) == 0 {
+		fmt.Fprintln(os.Stderr, a...)
+	}
+}